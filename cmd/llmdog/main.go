@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/doganarif/llmdog/internal/model"
+	"github.com/doganarif/llmdog/internal/tokens"
 	"github.com/doganarif/llmdog/internal/ui"
 )
 
@@ -27,27 +35,436 @@ const (
 
 func main() {
 	// Parse command-line arguments
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
-		case "-v", "--version":
-			fmt.Printf("llmdog version %s\n", version)
-			os.Exit(0)
+	var (
+		showVersion    bool
+		showAbout      bool
+		printHash      bool
+		modifiedWithin string
+		verbose        bool
+		logFile        string
+		noTUI          bool
+		watch          bool
+		repeat         bool
+		recent         bool
+		initConfig     bool
+		quiet          bool
+		cost           bool
+		conversation   string
+		tree           bool
+		maxDepth       int
+		opts           model.Options
+	)
+	flag.BoolVar(&showVersion, "v", false, "Show version")
+	flag.BoolVar(&showVersion, "version", false, "Show version")
+	flag.BoolVar(&showAbout, "about", false, "About llmdog")
+	flag.StringVar(&opts.Format, "format", "", "Output format")
+	flag.BoolVar(&printHash, "print-hash", false, "Print the SHA-256 of the copied output")
+	flag.StringVar(&modifiedWithin, "modified-within", "", "Pre-select files modified within a window")
+	flag.BoolVar(&verbose, "verbose", false, "Write detailed logs to the log file")
+	flag.StringVar(&logFile, "log-file", "", "Write logs to the given file")
+	flag.IntVar(&opts.MaxTokens, "max-tokens", 0, "Drop the largest files until the output fits this token budget")
+	flag.BoolVar(&noTUI, "no-tui", false, "Write the output to stdout without starting the TUI")
+	flag.StringVar(&opts.OutputFile, "output", "", "Write the output to a file")
+	flag.BoolVar(&opts.Force, "force", false, "Overwrite the output file without asking")
+	flag.BoolVar(&watch, "watch", false, "Rewrite the output file whenever a selected file changes")
+	flag.BoolVar(&opts.RunCommand, "run-command", false, "Run the configured contextCommand and include its output")
+	flag.StringVar(&opts.Context, "context", "", "Select the files listed in .llmdog/NAME.txt")
+	flag.BoolVar(&opts.Signatures, "signatures", false, "Include only top-level declarations of Go files")
+	flag.BoolVar(&opts.Safe, "safe", false, "Don't read file contents for previews or search, only for the output")
+	flag.BoolVar(&opts.EntryPointsFirst, "entry-points-first", false, "Put entry point files such as main.go first in the output")
+	flag.BoolVar(&opts.ImportGraph, "import-graph", false, "Add a section showing which selected Go files import which")
+	flag.BoolVar(&tree, "tree", false, "Print the file tree and exit")
+	flag.IntVar(&maxDepth, "max-depth", 0, "Limit --tree to this many levels")
+	flag.BoolVar(&repeat, "repeat", false, "Re-run the last --no-tui invocation")
+	flag.BoolVar(&recent, "recent", false, "Pick one of the recently browsed directories to open")
+	flag.BoolVar(&cost, "cost", false, "Estimate the input cost of the output with the configured models")
+	flag.BoolVar(&quiet, "quiet", false, "Don't print the stats line after writing the output")
+	flag.BoolVar(&initConfig, "init", false, "Write the global config file with every option set to its default")
+	flag.Var((*stringList)(&opts.Select), "select", "Select the files matching a glob (repeatable)")
+	flag.StringVar(&conversation, "conversation", "", "Append the output as a new user turn to a conversation file")
+	flag.StringVar(&opts.FromLog, "from-log", "", "Select the files referenced as path:line in a log file")
+	flag.Usage = func() {
+		fmt.Print(getHelpText())
+	}
+	flag.Parse()
+
+	if repeat {
+		last, err := model.LoadLastCommand()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot repeat:", err)
+			os.Exit(1)
+		}
+		if err := os.Chdir(last.Dir); err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot repeat:", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Repeating in %s: llmdog %s\n", last.Dir, strings.Join(last.Args, " "))
+		flag.CommandLine.Parse(last.Args)
+		// Refreshing the same output file is the point of repeating
+		opts.Force = true
+	}
+
+	if flag.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "Only one path may be given")
+		os.Exit(2)
+	}
+	opts.Root = model.ExpandPath(flag.Arg(0))
+	opts.OutputFile = model.ExpandPath(opts.OutputFile)
+	logFile = model.ExpandPath(logFile)
+	opts.FromLog = model.ExpandPath(opts.FromLog)
+	conversation = model.ExpandPath(conversation)
+
+	if recent {
+		if opts.Root != "" {
+			fmt.Fprintln(os.Stderr, "--recent picks the path, so none may be given")
+			os.Exit(2)
+		}
+		dir, err := pickRecentDir()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if dir == "" {
+			return
+		}
+		opts.Root = dir
+	}
+
+	switch {
+	case showVersion:
+		fmt.Printf("llmdog version %s\n", version)
+		os.Exit(0)
+
+	case showAbout:
+		fmt.Print(getAboutText())
+		os.Exit(0)
+
+	case initConfig:
+		path, err := model.InitConfig(opts.Force)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot write config:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote the default config to %s\n", path)
+		os.Exit(0)
+	}
+
+	closeLog, err := setupLogging(verbose, logFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not open log file: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeLog()
+
+	if modifiedWithin != "" {
+		window, err := model.ParseAge(modifiedWithin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.ModifiedWithin = window
+	}
+
+	if watch && opts.OutputFile == "" {
+		fmt.Fprintln(os.Stderr, "--watch needs --output")
+		os.Exit(2)
+	}
+
+	switch opts.Format {
+	case "", model.FormatMarkdown, model.FormatCompact, model.FormatJSONL, model.FormatOpenAI:
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format: %s\n", opts.Format)
+		os.Exit(2)
+	}
+
+	// Print just the project map
+	if tree {
+		m := model.New(opts)
+		if err := m.WriteTree(os.Stdout, maxDepth); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing tree:", err)
+			closeLog()
+			os.Exit(1)
+		}
+		return
+	}
 
-		case "-h", "--help":
-			fmt.Print(getHelpText())
-			os.Exit(0)
+	// Write straight to stdout for pipelines
+	if noTUI {
+		if !repeat {
+			if dir, err := os.Getwd(); err == nil {
+				if err := model.SaveLastCommand(model.LastCommand{Dir: dir, Args: os.Args[1:]}); err != nil {
+					slog.Warn("could not save last command", "err", err)
+				}
+			}
+		}
 
-		case "--about":
-			fmt.Print(getAboutText())
-			os.Exit(0)
+		m := model.New(opts)
+		var out io.Writer = os.Stdout
+		if opts.OutputFile != "" {
+			// There is no prompt without the TUI, so refuse to clobber a file
+			if _, err := os.Stat(opts.OutputFile); err == nil && !opts.Force {
+				fmt.Fprintf(os.Stderr, "%s already exists, use --force to overwrite it\n", opts.OutputFile)
+				closeLog()
+				os.Exit(1)
+			}
+			file, err := os.Create(opts.OutputFile)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error creating output file:", err)
+				closeLog()
+				os.Exit(1)
+			}
+			defer file.Close()
+			out = file
+		}
+		// The conversation takes the place of stdout
+		var turn strings.Builder
+		if conversation != "" && opts.OutputFile == "" {
+			out = &turn
+		} else if conversation != "" {
+			out = io.MultiWriter(out, &turn)
+		}
+		// Counted as it is written, since JSON Lines output is streamed
+		counter := &countingWriter{w: out}
+		if err := m.WriteSelection(counter); err != nil {
+			slog.Error("writing output failed", "err", err)
+			fmt.Fprintln(os.Stderr, "Error writing output:", err)
+			closeLog()
+			os.Exit(1)
+		}
+		reportDropped(m.DroppedFiles())
+		reportOverTokenLimit(m.OverTokenLimit())
+		if !quiet {
+			reportStats("Wrote", m.OutputFiles(), counter.n)
+		}
+		if cost {
+			reportCosts(m.Costs(tokens.EstimateSize(counter.n)))
 		}
+		if conversation != "" {
+			appendConversation(conversation, turn.String(), closeLog)
+		}
+		if watch {
+			watchOutput(m, opts.OutputFile)
+		}
+		return
+	}
+
+	app := model.New(opts)
+
+	// A single file is copied right away, there is nothing to pick
+	if app.SingleFile() && opts.OutputFile == "" {
+		if err := app.CopyOutput(); app.ClipboardFailed() {
+			fmt.Fprintln(os.Stderr, "Could not copy to the clipboard, writing the output to stdout instead")
+			fmt.Print(app.Output())
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot copy:", err)
+			closeLog()
+			os.Exit(1)
+		} else {
+			if !quiet {
+				reportStats("Copied", app.OutputFiles(), int64(len(app.Output())))
+			}
+		}
+		if cost {
+			reportCosts(app.Costs(tokens.Estimate(app.Output())))
+		}
+		reportOverTokenLimit(app.OverTokenLimit())
+		if conversation != "" {
+			appendConversation(conversation, app.Output(), closeLog)
+		}
+		if printHash {
+			fmt.Printf("SHA-256: %x\n", sha256.Sum256([]byte(app.Output())))
+		}
+		return
 	}
 
 	// Initialize the application
-	p := tea.NewProgram(model.New(), tea.WithAltScreen())
-	if err := p.Start(); err != nil {
-		log.Fatal("Error running program:", err)
+	p := tea.NewProgram(app, tea.WithAltScreen())
+	finalModel, err := p.Run()
+	if err != nil {
+		slog.Error("program failed", "err", err)
+		fmt.Fprintln(os.Stderr, "Error running program:", err)
+		closeLog()
+		os.Exit(1)
+	}
+
+	m, ok := finalModel.(*model.Model)
+	if !ok {
+		return
+	}
+
+	reportDropped(m.DroppedFiles())
+	reportOverTokenLimit(m.OverTokenLimit())
+
+	if err := m.RecordRecent(); err != nil {
+		slog.Warn("could not record recent directory", "err", err)
+	}
+
+	// Without a clipboard the confirmed output still goes somewhere
+	if m.Confirmed() && m.ClipboardFailed() {
+		fmt.Fprintln(os.Stderr, "Could not copy to the clipboard, writing the output to stdout instead")
+		fmt.Print(m.Output())
+	}
+
+	if conversation != "" && m.Confirmed() {
+		appendConversation(conversation, m.Output(), closeLog)
+	}
+
+	// Print a content hash so pipelines can detect unchanged context
+	if printHash && m.Output() != "" {
+		fmt.Printf("SHA-256: %x\n", sha256.Sum256([]byte(m.Output())))
 	}
+
+	if err := m.RunPostCopyCommand(); err != nil {
+		slog.Error("post-copy command failed", "err", err)
+		fmt.Fprintln(os.Stderr, err)
+		closeLog()
+		os.Exit(1)
+	}
+
+	if watch && m.Confirmed() {
+		watchOutput(m, opts.OutputFile)
+	}
+}
+
+// watchOutput keeps the output file up to date until interrupted
+func watchOutput(m *model.Model, outputFile string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := m.Watch(ctx, outputFile, os.Stderr); err != nil {
+		slog.Error("watch failed", "err", err)
+		fmt.Fprintln(os.Stderr, "Error watching files:", err)
+		os.Exit(1)
+	}
+}
+
+// pickRecentDir shows the recently browsed directories and returns the one
+// picked, or "" when the picker was cancelled
+func pickRecentDir() (string, error) {
+	dirs, err := model.LoadRecentDirs()
+	if err != nil {
+		return "", fmt.Errorf("could not load recent directories: %w", err)
+	}
+	if len(dirs) == 0 {
+		return "", fmt.Errorf("no recent directories yet; they are remembered once llmdog is closed")
+	}
+
+	var items []ui.RecentItem
+	for _, dir := range dirs {
+		items = append(items, ui.RecentItem{Dir: dir.Dir, Selected: dir.Selected, Used: dir.Used})
+	}
+	picker, err := tea.NewProgram(ui.NewRecentPicker(items), tea.WithAltScreen()).Run()
+	if err != nil {
+		return "", err
+	}
+	return picker.(*ui.RecentPicker).Choice(), nil
+}
+
+// reportStats prints what the output holds on stderr, keeping stdout clean for pipes
+func reportStats(verb string, files int, bytes int64) {
+	noun := "files"
+	if files == 1 {
+		noun = "file"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d %s, %d bytes, ~%d tokens\n", verb, files, noun, bytes, tokens.EstimateSize(bytes))
+}
+
+// appendConversation adds output as a new user turn to the conversation file,
+// exiting when it can't
+func appendConversation(path, output string, closeLog func()) {
+	if err := model.AppendConversation(path, output, time.Now()); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot append to conversation:", err)
+		closeLog()
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Appended a user turn to %s\n", path)
+}
+
+// reportCosts prints the estimated input cost with each model on stderr
+func reportCosts(costs []string) {
+	if len(costs) == 0 {
+		fmt.Fprintln(os.Stderr, "No model prices configured, set modelPrices in the config")
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Estimated input cost:")
+	for _, cost := range costs {
+		fmt.Fprintf(os.Stderr, "  %s\n", cost)
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// stringList collects the values of a flag given more than once
+type stringList []string
+
+// Implement flag.Value interface
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// reportDropped lists the files left out to fit --max-tokens on stderr
+func reportDropped(dropped []string) {
+	if len(dropped) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Dropped %d files to fit --max-tokens:\n", len(dropped))
+	for _, path := range dropped {
+		fmt.Fprintf(os.Stderr, "  %s\n", path)
+	}
+}
+
+// reportOverTokenLimit lists the files left out for exceeding maxFileTokens on stderr
+func reportOverTokenLimit(over []string) {
+	if len(over) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Skipped %d files over maxFileTokens:\n", len(over))
+	for _, path := range over {
+		fmt.Fprintf(os.Stderr, "  %s\n", path)
+	}
+}
+
+// setupLogging routes all logging to a file so it never corrupts the TUI.
+// Without --verbose or --log-file, logs are discarded.
+func setupLogging(verbose bool, logFile string) (func(), error) {
+	if !verbose && logFile == "" {
+		slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+		return func() {}, nil
+	}
+
+	if logFile == "" {
+		logFile = filepath.Join(os.Getenv("HOME"), ".config", "llmdog", "llmdog.log")
+		os.MkdirAll(filepath.Dir(logFile), 0755)
+	}
+
+	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{Level: level})))
+	slog.Info("llmdog started", "version", version, "args", os.Args[1:])
+
+	return func() { file.Close() }, nil
 }
 
 func getHelpText() string {
@@ -56,23 +473,75 @@ func getHelpText() string {
 		"llmdog - Prepare files for LLM consumption",
 		"",
 		ui.EmphasisStyle.Render("USAGE:"),
-		"  llmdog [options]",
+		"  llmdog [options] [path]",
+		"",
+		"  path can be a directory to browse or a single file to copy",
 		"",
 		ui.EmphasisStyle.Render("OPTIONS:"),
 		"  -h, --help      Show this help message",
 		"  -v, --version   Show version",
 		"  --about         About llmdog",
+		"  --format NAME   Output format: markdown (default), compact, jsonl or openai",
+		"  --no-tui        Write the output for the path to stdout without the TUI",
+		"  --tree          Print the file tree of the path and exit",
+		"  --max-depth N   Limit --tree to N levels",
+		"  --output PATH   Also write the confirmed output to PATH (instead of stdout with --no-tui)",
+		"  --force         Overwrite the --output file (or --init config) without asking",
+		"  --cost          Estimate the input cost per model, without the TUI",
+		"  --quiet         Don't print the stats line on stderr without the TUI",
+		"  --init          Write ~/.config/llmdog/config.json with every option",
+		"  --watch         Rewrite the --output file whenever a selected file changes",
+		"  --signatures    Include only the top-level declarations of Go files",
+		"  --safe          Read file contents only to build the output",
+		"  --entry-points-first",
+		"                  Put entry point files such as main.go first in the output",
+		"  --import-graph  Show which selected Go files import which other selected packages",
+		"  --max-tokens N  Drop the largest files until the output fits N tokens",
+		"  --print-hash    Print the SHA-256 of the copied output on exit",
+		"  --context NAME  Select the files listed in .llmdog/NAME.txt (e.g. context)",
+		"  --select GLOB   Select the files matching GLOB, e.g. '**/*.go' (repeatable)",
+		"  --conversation PATH",
+		"                  Append the output as a timestamped user turn to PATH (.json or Markdown)",
+		"  --from-log PATH Select the files referenced as path:line in a build or test log",
+		"  --recent        Pick one of the last directories llmdog was used in to open",
+		"  --modified-within WINDOW",
+		"                  Pre-select files modified within WINDOW (e.g. 6h, 2d)",
+		"  --verbose       Write detailed logs to ~/.config/llmdog/llmdog.log",
+		"  --log-file PATH Write logs to PATH",
+		"  --repeat        Re-run the last --no-tui invocation in its directory",
+		"  --run-command   Run the configured contextCommand and include its output",
 		"",
 		ui.EmphasisStyle.Render("KEYS:"),
 		"  ↑/↓             Navigate items",
 		"  Space           Expand/collapse folder",
 		"  Tab             Select/unselect item",
 		"  /               Filter items",
-		"  Ctrl+A          Select all visible items",
+		"  n/N             Jump to next/previous search match",
+		"  Ctrl+A          Select all visible items (shows a breakdown to confirm)",
 		"  Ctrl+D          Deselect all items",
+		"  e               Toggle all files with the highlighted file's extension",
 		"  Ctrl+S          Toggle content search mode",
 		"  Ctrl+/          Toggle preview pane",
+		"  Ctrl+R          Clear preview cache and reload preview",
+		"  w               Show the gitignore rule hiding the highlighted item",
+		"  T               Toggle token estimates in the file list",
+		"  o               Toggle showing only the selected items",
+		"  P               Toggle relative paths in the file list",
+		"  t               Toggle folder preview between listing and output tree",
+		"  v               Toggle rendered/raw preview for markdown files",
 		"  Enter           Confirm selection",
+		"  y               Copy selection and keep llmdog open",
+		"  S               Review selected files and sizes before copying",
+		"  Ctrl+G          Pick modified, staged or untracked files",
+		"  Y               Copy only the list of selected paths",
+		"  C               Copy an llmdog --no-tui command for the selection",
+		"  m               Select files modified within a time window",
+		"  a               Attach a note to the highlighted file",
+		"  L               Limit highlighted file to a line range",
+		"  g               Go to a path (Tab completes)",
+		"  x               Deselect (or select) files by extension or suffix",
+		"  D               Expand folders one level deeper (cycles back to collapsed)",
+		"  R               Pick a line range in the preview pane",
 		"  Esc             Clear filter/errors",
 		"  q               Quit",
 	}
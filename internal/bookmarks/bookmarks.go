@@ -9,12 +9,13 @@ import (
 
 // Bookmark represents a saved selection pattern
 type Bookmark struct {
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	FilePaths   []string  `json:"filePaths"`
-	RootPath    string    `json:"rootPath"`
-	Created     time.Time `json:"created"`
-	Modified    time.Time `json:"modified"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	FilePaths   []string          `json:"filePaths"`
+	Notes       map[string]string `json:"notes,omitempty"` // Notes by relative path
+	RootPath    string            `json:"rootPath"`
+	Created     time.Time         `json:"created"`
+	Modified    time.Time         `json:"modified"`
 }
 
 // BookmarkStore manages all bookmarks
@@ -36,6 +36,85 @@ func GetBranch(path string) string {
 	return strings.TrimSpace(string(out))
 }
 
+// GetCommit gets the full hash of the current commit
+func GetCommit(path string) string {
+	cmd := exec.Command("git", "-C", path, "rev-parse", "HEAD")
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// GetTopLevel gets the root directory of the repository containing path
+func GetTopLevel(path string) string {
+	cmd := exec.Command("git", "-C", path, "rev-parse", "--show-toplevel")
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// WebURL converts a remote URL in SSH (git@host:owner/repo.git or
+// ssh://git@host/owner/repo.git) or HTTPS form to the repository's web page.
+// It returns "" for remotes it can't convert, such as local paths.
+func WebURL(remote string) string {
+	remote = strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+
+	var host, repoPath string
+	switch {
+	case strings.HasPrefix(remote, "https://"), strings.HasPrefix(remote, "http://"), strings.HasPrefix(remote, "ssh://"):
+		_, rest, _ := strings.Cut(remote, "://")
+		host, repoPath, _ = strings.Cut(rest, "/")
+		// Drop credentials and ports, which the web server doesn't use
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		if colon := strings.Index(host, ":"); colon >= 0 && strings.HasPrefix(remote, "ssh://") {
+			host = host[:colon]
+		}
+	case strings.Contains(remote, "@") && strings.Contains(remote, ":"):
+		// scp-like syntax: user@host:owner/repo
+		_, rest, _ := strings.Cut(remote, "@")
+		host, repoPath, _ = strings.Cut(rest, ":")
+	default:
+		return ""
+	}
+
+	if host == "" || repoPath == "" {
+		return ""
+	}
+	return "https://" + host + "/" + repoPath
+}
+
+// FileURL builds the web link to a file at a commit, relative to the
+// repository root, highlighting lines start to end when start is set.
+// GitLab and Bitbucket use their own URL layouts; other hosts get GitHub's.
+func FileURL(webURL, commit, relPath string, start, end int) string {
+	relPath = filepath.ToSlash(relPath)
+	switch {
+	case strings.Contains(webURL, "gitlab"):
+		url := fmt.Sprintf("%s/-/blob/%s/%s", webURL, commit, relPath)
+		if start > 0 {
+			url += fmt.Sprintf("#L%d-%d", start, end)
+		}
+		return url
+	case strings.Contains(webURL, "bitbucket"):
+		url := fmt.Sprintf("%s/src/%s/%s", webURL, commit, relPath)
+		if start > 0 {
+			url += fmt.Sprintf("#lines-%d:%d", start, end)
+		}
+		return url
+	default:
+		url := fmt.Sprintf("%s/blob/%s/%s", webURL, commit, relPath)
+		if start > 0 {
+			url += fmt.Sprintf("#L%d-L%d", start, end)
+		}
+		return url
+	}
+}
+
 // GetModifiedFiles gets a list of modified files in git
 func GetModifiedFiles(path string) ([]string, error) {
 	if !IsRepo(path) {
@@ -88,17 +167,55 @@ func GetStagedFiles(path string) ([]string, error) {
 	return files, nil
 }
 
-// ParseGitignore parses a .gitignore file into a regexp pattern
-func ParseGitignore(path string) (*regexp.Regexp, error) {
+// GetUntrackedFiles gets a list of untracked files in git that aren't ignored
+func GetUntrackedFiles(path string) ([]string, error) {
+	if !IsRepo(path) {
+		return nil, fmt.Errorf("not a git repository")
+	}
+
+	cmd := exec.Command("git", "-C", path, "ls-files", "--others", "--exclude-standard")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(out) == 0 {
+		return []string{}, nil
+	}
+
+	files := strings.Split(strings.TrimSpace(string(out)), "\n")
+
+	// Convert to absolute paths
+	for i, file := range files {
+		files[i] = filepath.Join(path, file)
+	}
+
+	return files, nil
+}
+
+// GitignoreRule is a single pattern from a .gitignore file
+type GitignoreRule struct {
+	Line    int    // Line number in the file
+	Pattern string // Pattern as written in the file
+	Regexp  *regexp.Regexp
+}
+
+// ParseGitignoreRules parses a .gitignore file into one rule per pattern.
+// Patterns that fail to compile are skipped and reported in the returned
+// error, while the remaining rules are still returned.
+func ParseGitignoreRules(path string) ([]GitignoreRule, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var patterns []string
+	var rules []GitignoreRule
+	var invalid []string
 	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	lineNumber := 0
 
 	for scanner.Scan() {
+		lineNumber++
 		line := strings.TrimSpace(scanner.Text())
 
 		// Skip empty lines and comments
@@ -106,28 +223,123 @@ func ParseGitignore(path string) (*regexp.Regexp, error) {
 			continue
 		}
 
-		// Convert gitignore pattern to regex
-		pattern := gitignoreToRegexp(line)
-		patterns = append(patterns, pattern)
+		// Convert gitignore pattern to regex, compiling each one on its own
+		// so a single bad pattern doesn't disable all filtering
+		re, err := regexp.Compile(gitignoreToRegexp(line))
+		if err != nil {
+			invalid = append(invalid, line)
+			continue
+		}
+		rules = append(rules, GitignoreRule{Line: lineNumber, Pattern: line, Regexp: re})
+	}
+
+	var parseErr error
+	if len(invalid) > 0 {
+		parseErr = fmt.Errorf("skipped invalid gitignore patterns in %s: %s", path, strings.Join(invalid, ", "))
+	}
+	return rules, parseErr
+}
+
+// MatchingRules returns the rules that match path
+func MatchingRules(rules []GitignoreRule, path string) []GitignoreRule {
+	var matches []GitignoreRule
+	for _, rule := range rules {
+		if rule.Regexp.MatchString(path) {
+			matches = append(matches, rule)
+		}
+	}
+	return matches
+}
+
+// ParseGitignore parses a .gitignore file into a regexp pattern.
+// Patterns that fail to compile are skipped and reported in the returned
+// error, while the regexp built from the remaining patterns is still returned.
+func ParseGitignore(path string) (*regexp.Regexp, error) {
+	rules, parseErr := ParseGitignoreRules(path)
+	if len(rules) == 0 {
+		return nil, parseErr
 	}
 
-	if len(patterns) == 0 {
+	re, err := CompileRules(rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile gitignore %s: %w", path, err)
+	}
+	return re, parseErr
+}
+
+// CompileRules joins rules into a single regexp matching any of them.
+// It returns nil when there are no rules.
+func CompileRules(rules []GitignoreRule) (*regexp.Regexp, error) {
+	if len(rules) == 0 {
 		return nil, nil
 	}
 
+	var patterns []string
+	for _, rule := range rules {
+		patterns = append(patterns, rule.Regexp.String())
+	}
+
 	// Join all patterns with OR
-	regexPattern := fmt.Sprintf("(%s)", strings.Join(patterns, "|"))
-	return regexp.Compile(regexPattern)
+	return regexp.Compile(fmt.Sprintf("(%s)", strings.Join(patterns, "|")))
+}
+
+// ParseExportIgnoreRules parses the patterns marked export-ignore in a
+// .gitattributes file, which git leaves out of archives. Patterns that fail to
+// compile are skipped and reported in the returned error.
+func ParseExportIgnoreRules(path string) ([]GitignoreRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []GitignoreRule
+	var invalid []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Fields(scanner.Text())
+
+		// Skip empty lines, comments and macro definitions
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[attr]") {
+			continue
+		}
+
+		// The last setting of the attribute on the line wins; -export-ignore
+		// and !export-ignore unset it
+		exported := false
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "export-ignore", "export-ignore=true":
+				exported = true
+			case "-export-ignore", "!export-ignore", "export-ignore=false":
+				exported = false
+			}
+		}
+		if !exported {
+			continue
+		}
+
+		re, err := regexp.Compile(gitignoreToRegexp(fields[0]))
+		if err != nil {
+			invalid = append(invalid, fields[0])
+			continue
+		}
+		rules = append(rules, GitignoreRule{Line: lineNumber, Pattern: fields[0], Regexp: re})
+	}
+
+	var parseErr error
+	if len(invalid) > 0 {
+		parseErr = fmt.Errorf("skipped invalid export-ignore patterns in %s: %s", path, strings.Join(invalid, ", "))
+	}
+	return rules, parseErr
 }
 
 // gitignoreToRegexp converts a gitignore pattern to a regular expression
 func gitignoreToRegexp(pattern string) string {
-	// Escape special regex characters
-	pattern = regexp.QuoteMeta(pattern)
-
-	// Convert gitignore glob patterns to regex
-	pattern = strings.ReplaceAll(pattern, "\\*", ".*")
-	pattern = strings.ReplaceAll(pattern, "\\?", ".")
+	// Escape special regex characters and convert wildcards and character classes
+	pattern = quoteGlob(pattern)
 
 	// Handle directory separator
 	if strings.HasSuffix(pattern, "/") {
@@ -145,6 +357,82 @@ func gitignoreToRegexp(pattern string) string {
 	return pattern
 }
 
+// quoteGlob converts a glob pattern to a regex: * and ? become .* and ., and
+// character classes like [abc], [a-z] and [!ch] become regex classes. Other
+// regex metacharacters, those inside classes included, are escaped. A [
+// without a closing ] is treated as a literal.
+func quoteGlob(pattern string) string {
+	var sb strings.Builder
+	literalStart := 0
+
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '[' {
+			continue
+		}
+
+		// A ] right after the opening [ (or [!) is part of the class
+		j := i + 1
+		if j < len(pattern) && (pattern[j] == '!' || pattern[j] == '^') {
+			j++
+		}
+		if j < len(pattern) && pattern[j] == ']' {
+			j++
+		}
+		end := strings.IndexByte(pattern[j:], ']')
+		if end < 0 {
+			break
+		}
+		end += j
+
+		sb.WriteString(globLiteralToRegexp(pattern[literalStart:i]))
+		sb.WriteString(globClassToRegexp(pattern[i+1 : end]))
+		i = end
+		literalStart = end + 1
+	}
+
+	sb.WriteString(globLiteralToRegexp(pattern[literalStart:]))
+	return sb.String()
+}
+
+// globLiteralToRegexp converts the part of a glob pattern outside character
+// classes to a regex, where a backslash makes the next character literal
+func globLiteralToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*':
+			sb.WriteString(".*")
+		case c == '?':
+			sb.WriteString(".")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return sb.String()
+}
+
+// globClassToRegexp converts the contents of a glob character class to a regex class
+func globClassToRegexp(class string) string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+		sb.WriteString("^")
+		class = class[1:]
+	}
+	for _, r := range class {
+		// Brackets and backslashes are literal inside a glob class
+		if r == '\\' || r == '[' || r == ']' {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
 // GetFileDiff gets the diff for a specific file
 func GetFileDiff(repoPath, filePath string) (string, error) {
 	if !IsRepo(repoPath) {
@@ -166,6 +454,27 @@ func GetFileDiff(repoPath, filePath string) (string, error) {
 	return string(out), nil
 }
 
+// GetLastCommit gets the author and date of the last commit touching a file.
+// Both are empty if the file has no history.
+func GetLastCommit(repoPath, filePath string) (author, date string, err error) {
+	relPath, err := filepath.Rel(repoPath, filePath)
+	if err != nil {
+		return "", "", err
+	}
+
+	cmd := exec.Command("git", "-C", repoPath, "log", "-1", "--format=%an%x00%ad", "--date=short", "--", relPath)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", err
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(string(out)), "\x00", 2)
+	if len(parts) != 2 {
+		return "", "", nil
+	}
+	return parts[0], parts[1], nil
+}
+
 // GetRepoSummary gets a summary of the git repository
 func GetRepoSummary(path string) (map[string]string, error) {
 	if !IsRepo(path) {
@@ -213,4 +522,4 @@ func GetRepoSummary(path string) (map[string]string, error) {
 	}
 
 	return summary, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,44 @@
+package git
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGitignoreToRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		match   []string
+		noMatch []string
+	}{
+		{pattern: "*.[ch]", want: `.*\.[ch]`, match: []string{"main.c", "dir/x.h"}, noMatch: []string{"main.o", "main.go"}},
+		{pattern: "[a-z]*.go", want: `[a-z].*\.go`, match: []string{"main.go"}, noMatch: []string{"Main.go", "1.go"}},
+		{pattern: "file[!x].txt", want: `file[^x]\.txt`, match: []string{"filea.txt"}, noMatch: []string{"filex.txt"}},
+		{pattern: "[]a]", want: `[\]a]`, match: []string{"]", "a"}, noMatch: []string{"b"}},
+		{pattern: "a[bc", want: `a\[bc`, match: []string{"a[bc"}, noMatch: []string{"ab"}},
+		{pattern: `[\*]`, want: `[\\*]`, match: []string{"*", `\`}, noMatch: []string{"a"}},
+		{pattern: "x[*?]", want: `x[*?]`, match: []string{"x*", "x?"}, noMatch: []string{"xa", "x"}},
+		{pattern: "a?c", want: `a.c`, match: []string{"abc"}, noMatch: []string{"ac"}},
+		{pattern: `\*.log`, want: `\*\.log`, match: []string{"*.log"}, noMatch: []string{"a.log"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got := gitignoreToRegexp(tt.pattern)
+			if got != tt.want {
+				t.Fatalf("gitignoreToRegexp(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+			re := regexp.MustCompile("^" + got + "$")
+			for _, name := range tt.match {
+				if !re.MatchString(name) {
+					t.Errorf("%q does not match %q", tt.pattern, name)
+				}
+			}
+			for _, name := range tt.noMatch {
+				if re.MatchString(name) {
+					t.Errorf("%q matches %q", tt.pattern, name)
+				}
+			}
+		})
+	}
+}
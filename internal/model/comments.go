@@ -0,0 +1,532 @@
+package model
+
+import (
+	"strings"
+)
+
+// commentSyntax describes how comments and string literals look in a language
+type commentSyntax struct {
+	lineComments  []string // Line comment markers such as "//"
+	blockComments bool     // /* ... */ block comments
+	hashAnywhere  bool     // # starts a comment even right after a token
+	quotes        string   // Characters that start a string literal
+	rawBackticks  bool     // Backtick strings have no escapes (Go)
+	shellQuotes   bool     // Strings may span lines and single quotes have no escapes
+	valueQuotes   bool     // Quotes only start a string at the start of a value and single quotes have no escapes (YAML, TOML)
+	tripleQuotes  bool     // ''' and """ strings (Python)
+	regexLiterals bool     // /.../ regex literals (JavaScript)
+	lifetimes     bool     // ' can start a lifetime rather than a char literal (Rust)
+	cssURLs       bool     // Unquoted url(...) values are kept whole, since URLs contain // (SCSS)
+	heredocs      bool     // <<WORD here-document bodies are data, not code (shell)
+	cgoPreamble   bool     // The comment right before import "C" is C code (Go)
+	keepPrefixes  []string // Comments kept because they change how code builds
+}
+
+var (
+	cSyntax = commentSyntax{
+		lineComments:  []string{"//"},
+		blockComments: true,
+		quotes:        `"'`,
+	}
+	goSyntax = commentSyntax{
+		lineComments:  []string{"//"},
+		blockComments: true,
+		quotes:        "\"'`",
+		rawBackticks:  true,
+		cgoPreamble:   true,
+		keepPrefixes:  []string{"//go:", "// +build", "//export "},
+	}
+	jvmSyntax = commentSyntax{
+		lineComments:  []string{"//"},
+		blockComments: true,
+		quotes:        `"'`,
+		tripleQuotes:  true,
+	}
+	jsSyntax = commentSyntax{
+		lineComments:  []string{"//"},
+		blockComments: true,
+		quotes:        "\"'`",
+		regexLiterals: true,
+	}
+	rustSyntax = commentSyntax{
+		lineComments:  []string{"//"},
+		blockComments: true,
+		quotes:        `"'`,
+		lifetimes:     true,
+	}
+	cssSyntax = commentSyntax{
+		blockComments: true,
+		quotes:        `"'`,
+	}
+	scssSyntax = commentSyntax{
+		lineComments:  []string{"//"},
+		blockComments: true,
+		quotes:        `"'`,
+		cssURLs:       true,
+	}
+	pythonSyntax = commentSyntax{
+		lineComments: []string{"#"},
+		hashAnywhere: true,
+		quotes:       `"'`,
+		tripleQuotes: true,
+	}
+	shellSyntax = commentSyntax{
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		shellQuotes:  true,
+		heredocs:     true,
+	}
+	yamlSyntax = commentSyntax{
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		valueQuotes:  true,
+	}
+	tomlSyntax = commentSyntax{
+		lineComments: []string{"#"},
+		hashAnywhere: true,
+		quotes:       `"'`,
+		valueQuotes:  true,
+		tripleQuotes: true,
+	}
+)
+
+// commentSyntaxes maps file extensions to the comment syntax of their language
+var commentSyntaxes = map[string]commentSyntax{
+	"go":    goSyntax,
+	"c":     cSyntax,
+	"h":     cSyntax,
+	"cc":    cSyntax,
+	"cpp":   cSyntax,
+	"hpp":   cSyntax,
+	"cs":    cSyntax,
+	"java":  jvmSyntax,
+	"kt":    jvmSyntax,
+	"scala": jvmSyntax,
+	"swift": jvmSyntax,
+	"js":    jsSyntax,
+	"jsx":   jsSyntax,
+	"mjs":   jsSyntax,
+	"cjs":   jsSyntax,
+	"ts":    jsSyntax,
+	"tsx":   jsSyntax,
+	"rs":    rustSyntax,
+	"css":   cssSyntax,
+	"scss":  scssSyntax,
+	"py":    pythonSyntax,
+	"sh":    shellSyntax,
+	"bash":  shellSyntax,
+	"zsh":   shellSyntax,
+	"yaml":  yamlSyntax,
+	"yml":   yamlSyntax,
+	"toml":  tomlSyntax,
+}
+
+// stripComments removes comments from the source code of the given language
+// (a file extension, as returned by languageFor). String literals, and regex
+// literals in JavaScript, are skipped over so comment markers inside them are
+// kept, as are shell here-documents and the cgo preamble of Go files. Lines
+// left empty by a removed comment are dropped. Content in languages without
+// known syntax is returned unchanged.
+func stripComments(content []byte, language string) []byte {
+	syntax, ok := commentSyntaxes[strings.ToLower(language)]
+	if !ok {
+		return content
+	}
+
+	src := string(content)
+	var out, line strings.Builder
+	lineHadComment := false
+	var lastOut byte // Last non-blank byte written to out, for telling regexes from divisions
+	heredoc := ""    // Delimiter of the here-document starting on the next line
+	heredocTabs := false
+	cgo := syntax.cgoPreamble && strings.Contains(src, `import "C"`)
+
+	// flushLine ends the current line, dropping it if only a comment was on it
+	flushLine := func(newline bool) {
+		text := line.String()
+		if lineHadComment {
+			text = strings.TrimRight(text, " \t")
+		}
+		if !(lineHadComment && text == "") {
+			if trimmed := strings.TrimRight(text, " \t"); trimmed != "" {
+				lastOut = trimmed[len(trimmed)-1]
+			}
+			out.WriteString(text)
+			if newline {
+				out.WriteByte('\n')
+			}
+		}
+		line.Reset()
+		lineHadComment = false
+	}
+
+	// writeLiteral writes a literal that may span lines, ending the lines it covers
+	writeLiteral := func(literal string) {
+		for {
+			nl := strings.IndexByte(literal, '\n')
+			if nl < 0 {
+				line.WriteString(literal)
+				return
+			}
+			line.WriteString(literal[:nl])
+			lineHadComment = false // Keep blank lines inside the literal
+			flushLine(true)
+			literal = literal[nl+1:]
+		}
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+
+		switch {
+		case c == '\n':
+			flushLine(true)
+			i++
+			if heredoc != "" {
+				end := heredocEnd(src, i, heredoc, heredocTabs)
+				writeLiteral(src[i:end])
+				i = end
+				heredoc = ""
+			}
+
+		case i == 0 && strings.HasPrefix(src, "#!"):
+			// Keep shebang lines
+			end := lineEnd(src, i)
+			line.WriteString(src[i:end])
+			i = end
+
+		case cgo && cgoPreambleEnd(src, i) > i:
+			// cgo compiles the comment right before import "C" as C code
+			end := cgoPreambleEnd(src, i)
+			writeLiteral(src[i:end])
+			i = end
+
+		case syntax.blockComments && strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 4
+			}
+			// Block comments spanning lines end the lines they cover
+			for n := strings.Count(src[i:end], "\n"); n > 0; n-- {
+				lineHadComment = true
+				flushLine(true)
+			}
+			lineHadComment = true
+			i = end
+
+		case isLineComment(src, i, syntax):
+			end := lineEnd(src, i)
+			if hasAnyPrefix(src[i:end], syntax.keepPrefixes) {
+				line.WriteString(src[i:end])
+			} else {
+				lineHadComment = true
+			}
+			i = end
+
+		case syntax.heredocs && strings.HasPrefix(src[i:], "<<") && !strings.HasPrefix(src[i:], "<<<"):
+			end, delimiter, tabs := parseHeredoc(src, i)
+			if delimiter != "" {
+				heredoc, heredocTabs = delimiter, tabs
+			}
+			line.WriteString(src[i:end])
+			i = end
+
+		case syntax.cssURLs && isCSSURL(src, i):
+			end := cssURLEnd(src, i)
+			line.WriteString(src[i:end])
+			i = end
+
+		case syntax.valueQuotes && (c == '"' || c == '\'') && !startsValue(line.String()):
+			// Like the apostrophe in "desc: don't", not a string
+			line.WriteByte(c)
+			i++
+
+		case syntax.tripleQuotes && (strings.HasPrefix(src[i:], `"""`) || strings.HasPrefix(src[i:], `'''`)):
+			end := strings.Index(src[i+3:], src[i:i+3])
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 6
+			}
+			writeLiteral(src[i:end])
+			i = end
+
+		case c == '`' && strings.IndexByte(syntax.quotes, c) >= 0:
+			end := stringEnd(src, i, !syntax.rawBackticks, true)
+			writeLiteral(src[i:end])
+			i = end
+
+		case strings.IndexByte(syntax.quotes, c) >= 0:
+			if c == '\'' && syntax.lifetimes && !isRustCharLiteral(src, i) {
+				line.WriteByte(c)
+				i++
+				continue
+			}
+			end := stringEnd(src, i, !((syntax.shellQuotes || syntax.valueQuotes) && c == '\''), syntax.shellQuotes)
+			writeLiteral(src[i:end])
+			i = end
+
+		case c == '/' && syntax.regexLiterals && startsRegex(line.String(), lastOut):
+			end := regexEnd(src, i)
+			line.WriteString(src[i:end])
+			i = end
+
+		default:
+			line.WriteByte(c)
+			i++
+		}
+	}
+	flushLine(false)
+
+	return []byte(out.String())
+}
+
+// isLineComment reports whether a line comment starts at src[i]
+func isLineComment(src string, i int, syntax commentSyntax) bool {
+	for _, marker := range syntax.lineComments {
+		if !strings.HasPrefix(src[i:], marker) {
+			continue
+		}
+		// A # is only a comment at the start of a word in shell-like languages,
+		// so things like ${#var} and a#b are left alone
+		if marker == "#" && !syntax.hashAnywhere && i > 0 && !strings.ContainsRune(" \t\n;", rune(src[i-1])) {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// lineEnd returns the index of the newline ending the line that contains src[i]
+func lineEnd(src string, i int) int {
+	if end := strings.IndexByte(src[i:], '\n'); end >= 0 {
+		return i + end
+	}
+	return len(src)
+}
+
+// stringEnd returns the index just past the string literal starting at src[i].
+// Unless multiline is set, an unterminated string ends at the end of the line.
+func stringEnd(src string, i int, escapes, multiline bool) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch {
+		case escapes && src[j] == '\\':
+			j++
+		case src[j] == quote:
+			return j + 1
+		case src[j] == '\n' && !multiline:
+			return j
+		}
+	}
+	return len(src)
+}
+
+// isRustCharLiteral tells a char literal like 'a' or '\n' from a lifetime like 'a
+func isRustCharLiteral(src string, i int) bool {
+	if i+1 < len(src) && src[i+1] == '\\' {
+		return true
+	}
+	rest := src[i+1:]
+	for n, r := range rest {
+		if n == 0 {
+			continue
+		}
+		return r == '\''
+	}
+	return false
+}
+
+// startsRegex reports whether a / after the given text on its line starts a
+// regex literal rather than a division, judging by the last token before it.
+// At the start of a line, prev is the last non-blank byte of the lines before.
+func startsRegex(line string, prev byte) bool {
+	before := strings.TrimRight(line, " \t")
+	last := prev
+	if before != "" {
+		last = before[len(before)-1]
+	}
+	if last == 0 || strings.IndexByte("(,=:[!&|?{};+-*%<>~^", last) >= 0 {
+		return true
+	}
+	for _, keyword := range []string{"return", "typeof", "case", "do", "else", "in", "of", "void", "yield", "await"} {
+		if strings.HasSuffix(before, keyword) {
+			start := len(before) - len(keyword)
+			if start == 0 || !isIdentByte(before[start-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCSSURL reports whether an unquoted url( value starts at src[i]
+func isCSSURL(src string, i int) bool {
+	if len(src)-i < 5 || !strings.EqualFold(src[i:i+4], "url(") || (i > 0 && (isIdentByte(src[i-1]) || src[i-1] == '-')) {
+		return false
+	}
+	rest := strings.TrimLeft(src[i+4:], " \t")
+	return rest == "" || (rest[0] != '"' && rest[0] != '\'')
+}
+
+// cssURLEnd returns the index just past the unquoted url(...) starting at
+// src[i], or the end of the line if it isn't closed
+func cssURLEnd(src string, i int) int {
+	end := lineEnd(src, i)
+	if close := strings.IndexByte(src[i:end], ')'); close >= 0 {
+		return i + close + 1
+	}
+	return end
+}
+
+// parseHeredoc reads the here-document redirection starting at src[i], like
+// <<EOF, <<-'EOF' or << "EOF". It returns the index just past it, the
+// delimiter, or "" if there is none, and whether leading tabs are stripped.
+func parseHeredoc(src string, i int) (int, string, bool) {
+	j := i + 2
+	tabs := j < len(src) && src[j] == '-'
+	if tabs {
+		j++
+	}
+	for j < len(src) && (src[j] == ' ' || src[j] == '\t') {
+		j++
+	}
+	start := j
+	var delimiter strings.Builder
+	for j < len(src) && !strings.ContainsRune(" \t\n;|&<>()", rune(src[j])) {
+		switch c := src[j]; c {
+		case '"', '\'':
+			end := strings.IndexByte(src[j+1:lineEnd(src, j)], c)
+			if end < 0 {
+				return i + 2, "", false
+			}
+			delimiter.WriteString(src[j+1 : j+1+end])
+			j += end + 2
+		case '\\':
+			j++
+		default:
+			delimiter.WriteByte(c)
+			j++
+		}
+	}
+	// Shifts like $((1<<2)) aren't here-documents
+	if start < len(src) && src[start] >= '0' && src[start] <= '9' {
+		return i + 2, "", false
+	}
+	return j, delimiter.String(), tabs
+}
+
+// heredocEnd returns the index of the newline ending the line with delimiter,
+// which closes the here-document whose body starts at src[i]
+func heredocEnd(src string, i int, delimiter string, tabs bool) int {
+	for i < len(src) {
+		end := lineEnd(src, i)
+		text := src[i:end]
+		if tabs {
+			text = strings.TrimLeft(text, "\t")
+		}
+		if text == delimiter {
+			return end
+		}
+		if end == len(src) {
+			return end
+		}
+		i = end + 1
+	}
+	return len(src)
+}
+
+// cgoPreambleEnd returns the index just past the comments starting at src[i]
+// if they are directly followed by import "C", or -1 otherwise
+func cgoPreambleEnd(src string, i int) int {
+	j := i
+	for {
+		switch {
+		case strings.HasPrefix(src[j:], "/*"):
+			end := strings.Index(src[j+2:], "*/")
+			if end < 0 {
+				return -1
+			}
+			j += end + 4
+		case strings.HasPrefix(src[j:], "//"):
+			j = lineEnd(src, j)
+		default:
+			return -1
+		}
+		end := j
+		// Only spaces and a single newline may separate the comments from the import
+		for j < len(src) && (src[j] == ' ' || src[j] == '\t') {
+			j++
+		}
+		if j < len(src) && src[j] == '\n' {
+			j++
+			for j < len(src) && (src[j] == ' ' || src[j] == '\t') {
+				j++
+			}
+		}
+		if strings.HasPrefix(src[j:], `import "C"`) {
+			return end
+		}
+	}
+}
+
+// startsValue reports whether a quote after the given text on its line is at
+// the start of a YAML or TOML value, where it opens a string
+func startsValue(line string) bool {
+	before := strings.TrimRight(line, " \t")
+	if before == "" {
+		return true
+	}
+	last := before[len(before)-1]
+	if last == '-' {
+		// A list item, but not a dash inside a plain value
+		return len(before) == 1 || before[len(before)-2] == ' ' || before[len(before)-2] == '\t'
+	}
+	return strings.IndexByte(":=[{,?", last) >= 0
+}
+
+// regexEnd returns the index just past the regex literal starting at src[i],
+// including any flags
+func regexEnd(src string, i int) int {
+	inClass := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			// Not a regex after all, so treat the / as an operator
+			return i + 1
+		case '/':
+			if inClass {
+				continue
+			}
+			j++
+			for j < len(src) && isIdentByte(src[j]) {
+				j++
+			}
+			return j
+		}
+	}
+	return i + 1
+}
+
+// isIdentByte reports whether c can be part of an identifier
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// hasAnyPrefix reports whether s starts with any of the prefixes
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package model
+
+import "testing"
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		in, want string
+	}{
+		{
+			name:     "go line and block comments",
+			language: "go",
+			in:       "package a\n\n// Doc\nfunc F() int { /* inline */ return 1 } // trailing\n",
+			want:     "package a\n\nfunc F() int {  return 1 }\n",
+		},
+		{
+			name:     "go raw strings and runes",
+			language: "go",
+			in:       "var s = `// not a comment\n/* nor this */`\nvar r = '/' // c\nvar q = \"//\"\n",
+			want:     "var s = `// not a comment\n/* nor this */`\nvar r = '/'\nvar q = \"//\"\n",
+		},
+		{
+			name:     "go build directives",
+			language: "go",
+			in:       "//go:build linux\n// +build linux\n\npackage a\n\n//go:generate stringer\n//export F\n",
+			want:     "//go:build linux\n// +build linux\n\npackage a\n\n//go:generate stringer\n//export F\n",
+		},
+		{
+			name:     "go cgo block preamble",
+			language: "go",
+			in:       "package a\n\n/*\n#include <stdio.h>\n// helper\nstatic void f() {}\n*/\nimport \"C\"\n\n// Doc\nfunc F() {}\n",
+			want:     "package a\n\n/*\n#include <stdio.h>\n// helper\nstatic void f() {}\n*/\nimport \"C\"\n\nfunc F() {}\n",
+		},
+		{
+			name:     "go cgo line preamble",
+			language: "go",
+			in:       "package a\n\n// #cgo LDFLAGS: -lm\n// #include <math.h>\nimport \"C\"\n",
+			want:     "package a\n\n// #cgo LDFLAGS: -lm\n// #include <math.h>\nimport \"C\"\n",
+		},
+		{
+			name:     "go comment separated from import C",
+			language: "go",
+			in:       "package a\n\n// Not a preamble\n\nimport \"C\"\n",
+			want:     "package a\n\n\nimport \"C\"\n",
+		},
+		{
+			name:     "c strings and chars",
+			language: "c",
+			in:       "char *s = \"/* x */\"; // c\nchar c = '\"'; /* b */\n",
+			want:     "char *s = \"/* x */\";\nchar c = '\"';\n",
+		},
+		{
+			name:     "java text block",
+			language: "java",
+			in:       "String s = \"\"\"\n  /* kept */\n  // kept\n  \"\"\"; // c\n",
+			want:     "String s = \"\"\"\n  /* kept */\n  // kept\n  \"\"\";\n",
+		},
+		{
+			name:     "kotlin raw string",
+			language: "kt",
+			in:       "val s = \"\"\"\n// kept\n\"\"\"\n// dropped\nval t = 1\n",
+			want:     "val s = \"\"\"\n// kept\n\"\"\"\nval t = 1\n",
+		},
+		{
+			name:     "swift multiline string",
+			language: "swift",
+			in:       "let s = \"\"\"\n  // kept\n  \"\"\" // c\n",
+			want:     "let s = \"\"\"\n  // kept\n  \"\"\"\n",
+		},
+		{
+			name:     "js regex and division",
+			language: "js",
+			in:       "const re = /\\/\\/ not/g; // c\nconst x = a / b / c; // d\nif (/[/]/.test(s)) {}\nreturn /a/\n",
+			want:     "const re = /\\/\\/ not/g;\nconst x = a / b / c;\nif (/[/]/.test(s)) {}\nreturn /a/\n",
+		},
+		{
+			name:     "js template literal",
+			language: "ts",
+			in:       "const s = `a\n// kept ${x}`\n// dropped\n",
+			want:     "const s = `a\n// kept ${x}`\n",
+		},
+		{
+			name:     "rust lifetimes and chars",
+			language: "rs",
+			in:       "fn f<'a>(s: &'a str) -> char { '\"' } // c\nlet c = '/'; /* b */\n",
+			want:     "fn f<'a>(s: &'a str) -> char { '\"' }\nlet c = '/';\n",
+		},
+		{
+			name:     "css",
+			language: "css",
+			in:       "a { /* c */ background: url(http://x/y.png); }\n",
+			want:     "a {  background: url(http://x/y.png); }\n",
+		},
+		{
+			name:     "scss unquoted url",
+			language: "scss",
+			in:       "a { background: url(http://x/y.png); } // c\n// dropped\nb { c: \"//\"; }\n",
+			want:     "a { background: url(http://x/y.png); }\nb { c: \"//\"; }\n",
+		},
+		{
+			name:     "python triple quotes",
+			language: "py",
+			in:       "def f():\n    '''Doc # kept'''\n    s = \"#\"  # c\n    return s#d\n",
+			want:     "def f():\n    '''Doc # kept'''\n    s = \"#\"\n    return s\n",
+		},
+		{
+			name:     "shell",
+			language: "sh",
+			in:       "#!/bin/sh\n# c\necho \"# kept\" 'it''s' ${#x} a#b # d\n",
+			want:     "#!/bin/sh\necho \"# kept\" 'it''s' ${#x} a#b\n",
+		},
+		{
+			name:     "shell heredocs",
+			language: "sh",
+			in:       "cat <<EOF # c\n# kept\nEOF\ncat <<-'END'\n\t# kept\n\tEND\necho $((1<<2)) # d\n# dropped\n",
+			want:     "cat <<EOF\n# kept\nEOF\ncat <<-'END'\n\t# kept\n\tEND\necho $((1<<2))\n",
+		},
+		{
+			name:     "yaml quotes",
+			language: "yaml",
+			in:       "desc: don't # c\nb: 'has # hash'\nlist:\n  - 'a # b'\n  - \"it's\" # d\n# dropped\n",
+			want:     "desc: don't\nb: 'has # hash'\nlist:\n  - 'a # b'\n  - \"it's\"\n",
+		},
+		{
+			name:     "toml",
+			language: "toml",
+			in:       "a = \"\"\"\n# kept\n\"\"\"\nb = 'c:\\path' # c\nn = 1#c\n",
+			want:     "a = \"\"\"\n# kept\n\"\"\"\nb = 'c:\\path'\nn = 1\n",
+		},
+		{
+			name:     "unknown language",
+			language: "rb",
+			in:       "# kept\nputs 1\n",
+			want:     "# kept\nputs 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(stripComments([]byte(tt.in), tt.language)); got != tt.want {
+				t.Errorf("stripComments(%q, %q) = %q, want %q", tt.in, tt.language, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// contentScan is a content search running in the background
+type contentScan struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	total  int
+	done   atomic.Int64 // Files scanned so far
+}
+
+// contentSearchProgressMsg redraws the progress of the content search seq
+type contentSearchProgressMsg struct{ seq int }
+
+// contentSearchDoneMsg carries the result of a search of all loaded files
+type contentSearchDoneMsg struct {
+	seq     int
+	query   string
+	matches map[string]bool
+	submit  bool // The search was confirmed with Enter
+}
+
+// contentSearchProgressInterval is how often the progress bar is redrawn
+const contentSearchProgressInterval = 100 * time.Millisecond
+
+// maxContentSearchSize skips larger files when searching contents
+const maxContentSearchSize = 1024 * 1024
+
+// startContentScan cancels the content search in flight and sets up a new
+// one over total files
+func (m *Model) startContentScan(total int) *contentScan {
+	if m.contentScan != nil {
+		m.contentScan.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.contentScan = &contentScan{ctx: ctx, cancel: cancel, total: total}
+	return m.contentScan
+}
+
+// run searches the contents of paths for queryLower with a worker per CPU and
+// returns the matching paths, or nil once the scan is cancelled
+func (s *contentScan) run(paths []string, queryLower string) map[string]bool {
+	jobs := make(chan string)
+	matches := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				if fileContains(path, queryLower) {
+					mu.Lock()
+					matches[path] = true
+					mu.Unlock()
+				}
+				s.done.Add(1)
+			}
+		}()
+	}
+
+feed:
+	for _, path := range paths {
+		select {
+		case jobs <- path:
+		case <-s.ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if s.ctx.Err() != nil {
+		return nil
+	}
+	return matches
+}
+
+// fileContains reports whether the regular file at path contains queryLower,
+// ignoring case. Files over maxContentSearchSize never match.
+func fileContains(path, queryLower string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() >= maxContentSearchSize {
+		return false
+	}
+	content, err := os.ReadFile(path)
+	return err == nil && strings.Contains(strings.ToLower(string(content)), queryLower)
+}
+
+// contentSearchProgressTick schedules the next redraw of the progress bar
+func contentSearchProgressTick(seq int) tea.Cmd {
+	return tea.Tick(contentSearchProgressInterval, func(time.Time) tea.Msg {
+		return contentSearchProgressMsg{seq: seq}
+	})
+}
+
+// searchAll searches the names of all loaded items and, with content search
+// on, their contents in the background. A search confirmed with Enter leaves
+// filtering once it has matches.
+func (m *Model) searchAll(query string, submit bool) tea.Cmd {
+	if !m.contentSearchMode || query == "" {
+		m.performSearch(query, nil)
+		m.finishSearch(submit)
+		return nil
+	}
+
+	var paths []string
+	for _, item := range m.items {
+		if !item.IsDir {
+			paths = append(paths, item.Path)
+		}
+	}
+
+	m.contentSearchSeq++
+	seq := m.contentSearchSeq
+	scan := m.startContentScan(len(paths))
+	queryLower := strings.ToLower(query)
+
+	return tea.Batch(func() tea.Msg {
+		matches := scan.run(paths, queryLower)
+		if matches == nil {
+			return nil
+		}
+		return contentSearchDoneMsg{seq: seq, query: query, matches: matches, submit: submit}
+	}, contentSearchProgressTick(seq))
+}
+
+// finishSearch leaves filtering after a search confirmed with Enter, so the
+// results stay visible and n/N work
+func (m *Model) finishSearch(submit bool) {
+	if submit && len(m.searchMatches) > 0 {
+		m.list.ResetFilter()
+	}
+}
+
+// view renders the progress of the scan as a bar that fits in width
+func (s *contentScan) view(width int) string {
+	done := min(int(s.done.Load()), s.total)
+	percent := 100
+	if s.total > 0 {
+		percent = done * 100 / s.total
+	}
+
+	prefix := "Searching contents "
+	suffix := fmt.Sprintf(" %3d%% (%d/%d files) • Esc:Cancel", percent, done, s.total)
+	barWidth := max(0, min(40, width-lipgloss.Width(prefix+suffix)))
+	filled := barWidth * percent / 100
+
+	return prefix + strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled) + suffix
+}
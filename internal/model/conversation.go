@@ -0,0 +1,85 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// conversationMessage is a turn appended to a JSON conversation file
+type conversationMessage struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	Timestamp string `json:"timestamp"`
+}
+
+// AppendConversation adds output as a new user turn at the end of the
+// conversation file at path, created when missing. A .json file holds a
+// messages array like the openai format, keeping any other fields; any other
+// file is Markdown with a heading per turn.
+func AppendConversation(path, output string, now time.Time) error {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return appendJSONConversation(path, output, now)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	turn := fmt.Sprintf("## User (%s)\n\n%s", now.Format(time.RFC3339), output)
+	if info.Size() == 0 {
+		turn = "# Conversation\n\n" + turn
+	} else {
+		turn = "\n" + turn
+	}
+	if !strings.HasSuffix(turn, "\n") {
+		turn += "\n"
+	}
+	_, err = file.WriteString(turn)
+	return err
+}
+
+// appendJSONConversation adds a user message to the messages array of the
+// JSON conversation file at path
+func appendJSONConversation(path, output string, now time.Time) error {
+	conversation := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			return fmt.Errorf("%s is not a JSON conversation: %v", path, err)
+		}
+	}
+
+	var messages []json.RawMessage
+	if raw, ok := conversation["messages"]; ok {
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			return fmt.Errorf("%s has no messages array: %v", path, err)
+		}
+	}
+	message, err := json.Marshal(conversationMessage{Role: "user", Content: output, Timestamp: now.Format(time.RFC3339)})
+	if err != nil {
+		return err
+	}
+	messages = append(messages, message)
+
+	if conversation["messages"], err = json.Marshal(messages); err != nil {
+		return err
+	}
+	data, err = json.MarshalIndent(conversation, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// logPathPattern matches path:line references such as those in compiler and
+// test output, like "internal/model/model.go:42:" or "./src/app.ts:10:5"
+var logPathPattern = regexp.MustCompile(`(?:^|[\s('"` + "`" + `])([^\s:'"()` + "`" + `]+):\d+`)
+
+// logFileExtPattern matches a file extension, which has to start with a letter
+// so that addresses like "10.0.0.1:8080" aren't taken for files
+var logFileExtPattern = regexp.MustCompile(`\.[A-Za-z][A-Za-z0-9_]*$`)
+
+// logPaths returns the distinct paths referenced as path:line in log, in the
+// order they first appear. Only references with a folder or a file extension
+// count, so timestamps like "12:30:45", "host:8080" or "line 3:5" are ignored.
+func logPaths(log string) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, match := range logPathPattern.FindAllStringSubmatch(log, -1) {
+		if !strings.Contains(match[1], "/") && !logFileExtPattern.MatchString(match[1]) {
+			continue
+		}
+		path := filepath.Clean(match[1])
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// selectFromLog selects the files referenced in the log file at logFile,
+// expanding their folders. References are relative to the working directory
+// or, like the file names in go test output, the unique file ending with them.
+func (m *Model) selectFromLog(logFile string) {
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		m.addError(fmt.Errorf("Cannot read log: %v", err))
+		return
+	}
+
+	files := make(map[string]bool)
+	for _, item := range m.items {
+		if !item.IsDir && !m.isGitIgnored(item.Path) {
+			files[item.Path] = true
+		}
+	}
+
+	found := make(map[string]bool)
+	var paths []string
+	missing := 0
+	for _, ref := range logPaths(string(data)) {
+		path := ref
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(m.cwd, path)
+		}
+		if !files[path] {
+			path = uniqueSuffixMatch(files, ref)
+		}
+		if path == "" {
+			missing++
+			continue
+		}
+		if !found[path] {
+			found[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, path := range paths {
+		m.toggleSelection(path, true)
+		m.ensureParentPathsExpanded(path)
+	}
+	m.refreshVisibleItems()
+
+	message := fmt.Sprintf("Selected %d files referenced in %s", len(paths), filepath.Base(logFile))
+	if missing > 0 {
+		message += fmt.Sprintf(" (%d references not in the tree)", missing)
+	}
+	m.setStatusMessage(message, 3)
+}
+
+// uniqueSuffixMatch returns the only file whose path ends with the relative
+// path ref, or an empty string when none or several do
+func uniqueSuffixMatch(files map[string]bool, ref string) string {
+	if filepath.IsAbs(ref) || strings.HasPrefix(ref, "..") {
+		return ""
+	}
+	suffix := string(filepath.Separator) + ref
+	match := ""
+	for path := range files {
+		if strings.HasSuffix(path, suffix) {
+			if match != "" {
+				return ""
+			}
+			match = path
+		}
+	}
+	return match
+}
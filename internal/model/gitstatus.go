@@ -0,0 +1,107 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/doganarif/llmdog/internal/git"
+	"github.com/doganarif/llmdog/internal/ui"
+)
+
+// openGitStatusMenu lists the modified, staged and untracked files with the
+// selected ones ticked
+func (m *Model) openGitStatusMenu() {
+	if !git.IsRepo(m.cwd) {
+		m.setStatusMessage("Not a git repository", 2)
+		return
+	}
+
+	sources := []struct {
+		name  string
+		files func(string) ([]string, error)
+	}{
+		{"Modified", git.GetModifiedFiles},
+		{"Staged", git.GetStagedFiles},
+		{"Untracked", git.GetUntrackedFiles},
+	}
+
+	// Only files in the tree can be selected, which leaves out deleted, hidden
+	// and excluded ones
+	selected := make(map[string]bool)
+	for _, item := range m.items {
+		if !item.IsDir {
+			selected[item.Path] = item.Selected
+		}
+	}
+
+	var categories []ui.GitStatusCategory
+	total := 0
+	for _, source := range sources {
+		files, err := source.files(m.cwd)
+		if err != nil {
+			m.addError(fmt.Errorf("git status: %s files: %v", source.name, err))
+			continue
+		}
+
+		category := ui.GitStatusCategory{Name: source.name}
+		for _, path := range files {
+			if _, ok := selected[path]; !ok {
+				continue
+			}
+			rel, err := filepath.Rel(m.cwd, path)
+			if err != nil {
+				rel = path
+			}
+			category.Files = append(category.Files, path)
+			category.Rel = append(category.Rel, rel)
+		}
+		total += len(category.Files)
+		categories = append(categories, category)
+	}
+	if total == 0 {
+		m.setStatusMessage("No modified, staged or untracked files", 2)
+		return
+	}
+
+	m.gitStatusMenu = ui.NewGitStatusMenu(categories, selected, m.termWidth/2, m.termHeight/2)
+	m.showGitStatus = true
+}
+
+// updateGitStatusMenu handles a key press while the git status menu is open.
+// Applying it selects the ticked files and deselects the others it lists.
+func (m *Model) updateGitStatusMenu(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "q", "ctrl+g":
+		m.showGitStatus = false
+		return nil
+
+	case " ", "tab":
+		m.gitStatusMenu.Toggle()
+		return nil
+
+	case "enter":
+		m.showGitStatus = false
+		selected := make(map[string]bool)
+		for _, item := range m.items {
+			selected[item.Path] = item.Selected
+		}
+		count := 0
+		for path, checked := range m.gitStatusMenu.Checked() {
+			if checked {
+				count++
+				m.ensureParentPathsExpanded(path)
+			}
+			if selected[path] != checked {
+				m.toggleSelection(path, checked)
+			}
+		}
+		m.refreshVisibleItems()
+		m.setStatusMessage(fmt.Sprintf("Selected %d files from git status", count), 2)
+		return nil
+	}
+
+	menu, cmd := m.gitStatusMenu.Update(msg)
+	m.gitStatusMenu = menu
+	return cmd
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"bufio"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/doganarif/llmdog/internal/ui"
+)
+
+// importGraphSection lists which selected Go files import the packages of
+// other selected files. Other languages are left out, and without any such
+// imports there is no section.
+func importGraphSection(items []ui.FileItem, cwd string) string {
+	moduleRoot, modulePath := findGoModule(cwd)
+	if modulePath == "" {
+		return ""
+	}
+
+	// Directories of the selected packages by import path
+	packages := make(map[string]string)
+	var files []ui.FileItem
+	for _, item := range items {
+		if item.IsDir || languageFor(item.Path) != "go" {
+			continue
+		}
+		dir := filepath.Dir(item.Path)
+		rel, err := filepath.Rel(moduleRoot, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		importPath := modulePath
+		if rel != "." {
+			importPath += "/" + filepath.ToSlash(rel)
+		}
+		packages[importPath] = relSlash(cwd, dir)
+		files = append(files, item)
+	}
+
+	var sb strings.Builder
+	for _, item := range files {
+		own := relSlash(cwd, filepath.Dir(item.Path))
+		var targets []string
+		for _, importPath := range goImports(item.Path) {
+			// External test packages importing their own package say nothing
+			if dir, ok := packages[importPath]; ok && dir != own {
+				targets = append(targets, dir)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		sort.Strings(targets)
+		sb.WriteString(relSlash(cwd, item.Path) + " -> " + strings.Join(targets, ", ") + "\n")
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "\n# Import Graph\n```\n" + sb.String() + "```\n"
+}
+
+// findGoModule returns the directory and module path of the go.mod in dir or
+// the closest directory above it, or empty strings when there is none
+func findGoModule(dir string) (string, string) {
+	for {
+		file, err := os.Open(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			defer file.Close()
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				if path, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+					return dir, strings.Trim(strings.TrimSpace(path), `"`)
+				}
+			}
+			return "", ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// goImports returns the import paths of the Go file at path, or nil when it
+// doesn't parse
+func goImports(path string) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+
+	var imports []string
+	for _, spec := range file.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports = append(imports, importPath)
+		}
+	}
+	return imports
+}
+
+// relSlash returns path relative to cwd with forward slashes, or path itself
+// when it can't be made relative
+func relSlash(cwd, path string) string {
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultKeyBindings maps each remappable action to its default key
+var defaultKeyBindings = map[string]string{
+	"quit":              "q",
+	"expand":            " ",
+	"select":            "tab",
+	"filter":            "/",
+	"togglePreview":     "ctrl+/",
+	"contentSearch":     "ctrl+s",
+	"selectAll":         "ctrl+a",
+	"deselectAll":       "ctrl+d",
+	"bookmarks":         "ctrl+b",
+	"lineRange":         "L",
+	"goToPath":          "g",
+	"expandDepth":       "D",
+	"pickRange":         "R",
+	"annotate":          "a",
+	"nextMatch":         "n",
+	"prevMatch":         "N",
+	"sameExtension":     "e",
+	"byExtension":       "x",
+	"copyPaths":         "Y",
+	"copyCommand":       "C",
+	"modifiedWithin":    "m",
+	"clearPreviewCache": "ctrl+r",
+	"toggleMarkdown":    "v",
+	"treePreview":       "t",
+	"relativePaths":     "P",
+	"selectedOnly":      "o",
+	"tokenBadges":       "T",
+	"gitignoreRule":     "w",
+	"reviewSelection":   "S",
+	"gitStatus":         "ctrl+g",
+	"copy":              "y",
+	"confirm":           "enter",
+}
+
+// normalizeKey accepts readable names for keys that are awkward to write in config files
+func normalizeKey(key string) string {
+	if strings.EqualFold(key, "space") {
+		return " "
+	}
+	return key
+}
+
+// keyRemap translates pressed keys to the default key of the action they are bound to
+type keyRemap map[string]string
+
+// newKeyRemap builds the translation table for the configured bindings. Keys of
+// remapped actions that aren't reused by another action are disabled. Unknown
+// actions and keys bound to more than one action are reported as errors.
+func newKeyRemap(bindings map[string]string) (keyRemap, error) {
+	keys := make(map[string]string)
+	for action, key := range defaultKeyBindings {
+		keys[action] = key
+	}
+
+	var problems []string
+	for action, key := range bindings {
+		if _, ok := defaultKeyBindings[action]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown action %q", action))
+			continue
+		}
+		if key == "" {
+			problems = append(problems, fmt.Sprintf("empty key for action %q", action))
+			continue
+		}
+		keys[action] = normalizeKey(key)
+	}
+
+	// Two actions on the same key can't both work
+	owners := make(map[string][]string)
+	for action, key := range keys {
+		owners[key] = append(owners[key], action)
+	}
+	for key, actions := range owners {
+		if len(actions) > 1 {
+			sort.Strings(actions)
+			problems = append(problems, fmt.Sprintf("key %q is bound to %s", key, strings.Join(actions, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid keyBindings: %s", strings.Join(problems, "; "))
+	}
+
+	// Free the default keys of remapped actions before binding the new keys,
+	// so swapping two keys works
+	remap := make(keyRemap)
+	for action, key := range keys {
+		if key != defaultKeyBindings[action] {
+			remap[defaultKeyBindings[action]] = ""
+		}
+	}
+	for action, key := range keys {
+		if key != defaultKeyBindings[action] {
+			remap[key] = defaultKeyBindings[action]
+		}
+	}
+	return remap, nil
+}
+
+// resolve returns the default key of the action bound to key, or "" if the key
+// was freed by a remapping
+func (r keyRemap) resolve(key string) string {
+	if mapped, ok := r[key]; ok {
+		return mapped
+	}
+	return key
+}
+
+// boundKey returns the key currently bound to action
+func (r keyRemap) boundKey(action string) string {
+	defaultKey := defaultKeyBindings[action]
+	for key, mapped := range r {
+		if mapped == defaultKey {
+			return key
+		}
+	}
+	return defaultKey
+}
@@ -1,17 +1,26 @@
 package model
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/doganarif/llmdog/internal/bookmarks"
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/list"
@@ -19,30 +28,173 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/doganarif/llmdog/internal/git"
+	"github.com/doganarif/llmdog/internal/tokens"
 	"github.com/doganarif/llmdog/internal/ui"
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds user configuration
 type Config struct {
-	ShowHiddenFiles   bool    `json:"showHiddenFiles"`
-	FuzzyThreshold    float64 `json:"fuzzyThreshold"`
-	MaxPreviewSize    int     `json:"maxPreviewSize"`
-	ColorTheme        string  `json:"colorTheme"`
-	ContentSearchMode bool    `json:"contentSearchMode"`
+	ShowHiddenFiles      bool                      `json:"showHiddenFiles"`
+	FuzzyThreshold       float64                   `json:"fuzzyThreshold"`
+	MaxPreviewSize       int                       `json:"maxPreviewSize"`
+	ColorTheme           string                    `json:"colorTheme"`
+	ContentSearchMode    bool                      `json:"contentSearchMode"`
+	IncludeEmptyDirs     bool                      `json:"includeEmptyDirs"`
+	OutputFormat         string                    `json:"outputFormat"`
+	TreeIndent           string                    `json:"treeIndent"`
+	TreeStyle            string                    `json:"treeStyle"`
+	NormalizeLineEndings bool                      `json:"normalizeLineEndings"`
+	FlatStructure        bool                      `json:"flatStructure"`
+	ExcludeDirs          []string                  `json:"excludeDirs"`
+	ContextCommand       string                    `json:"contextCommand"`
+	RecursiveDirCounts   bool                      `json:"recursiveDirCounts"`
+	CollapsibleFiles     bool                      `json:"collapsibleFiles"`
+	KeyBindings          map[string]string         `json:"keyBindings"`
+	PostCopyCommand      string                    `json:"postCopyCommand"`
+	RenderMarkdown       bool                      `json:"renderMarkdown"`
+	TableOfContents      bool                      `json:"tableOfContents"`
+	BookmarkNameTemplate string                    `json:"bookmarkNameTemplate"`
+	HeaderFormat         string                    `json:"headerFormat"`
+	IncludeBlame         bool                      `json:"includeBlame"`
+	FilesOnly            bool                      `json:"filesOnly"`
+	PreviewLines         int                       `json:"previewLines"`
+	ShowParentDirs       bool                      `json:"showParentDirs"`
+	StripComments        bool                      `json:"stripComments"`
+	Preamble             string                    `json:"preamble"`
+	PreambleFile         string                    `json:"preambleFile"`
+	IncludeExtensions    []string                  `json:"includeExtensions"`
+	Dedent               bool                      `json:"dedent"`
+	SkipFilesLargerThan  int64                     `json:"skipFilesLargerThan"`
+	RelativePaths        bool                      `json:"relativePaths"`
+	ChangedAsDiff        bool                      `json:"changedAsDiff"`
+	TokenBadges          bool                      `json:"tokenBadges"`
+	SignaturesOnly       bool                      `json:"signaturesOnly"`
+	EntryPointsFirst     bool                      `json:"entryPointsFirst"`
+	EntryPoints          []string                  `json:"entryPoints"`
+	ImportGraph          bool                      `json:"importGraph"`
+	ExportIgnore         bool                      `json:"exportIgnore"`
+	DockerIgnore         bool                      `json:"dockerIgnore"`
+	RangeContext         int                       `json:"rangeContext"`
+	SafeMode             bool                      `json:"safeMode"`
+	DetectProjectType    bool                      `json:"detectProjectType"`
+	ProjectProfiles      map[string]ProjectProfile `json:"projectProfiles"`
+	IncludeMetadata      bool                      `json:"includeMetadata"`
+	GitMetadata          []string                  `json:"gitMetadata"`
+	LinkFiles            bool                      `json:"linkFiles"`
+	ClipboardRetries     int                       `json:"clipboardRetries"`
+	MaxFileTokens        int                       `json:"maxFileTokens"`
+	ReviewBeforeCopy     bool                      `json:"reviewBeforeCopy"`
+	GroupByDirectory     bool                      `json:"groupByDirectory"`
+	IncludeStructure     bool                      `json:"includeStructure"`
+	IncludeReadme        bool                      `json:"includeReadme"`
+	HideFileInfo         bool                      `json:"hideFileInfo"`
+	ModelPrices          map[string]float64        `json:"modelPrices"` // USD per million input tokens
+	RunContextCommand    bool                      `json:"-"`           // Only set from the command line, never from config files
+}
+
+// Output formats supported by BuildOutput
+const (
+	FormatMarkdown = "markdown"
+	FormatCompact  = "compact"
+	FormatJSONL    = "jsonl"
+	FormatOpenAI   = "openai"
+)
+
+// defaultPreamble is the system message of the openai format when no preamble is configured
+const defaultPreamble = "You are a helpful assistant. The user is sharing files from their project as context for their questions."
+
+// Tree styles supported by the directory structure section
+const (
+	TreeStyleASCII   = "ascii"
+	TreeStyleUnicode = "unicode"
+)
+
+// Options holds command-line settings that take precedence over config files
+type Options struct {
+	Format           string
+	ModifiedWithin   time.Duration // Pre-select files modified within this window
+	RunCommand       bool          // Run Config.ContextCommand and include its output
+	Root             string        // Directory or single file to open instead of the working directory
+	MaxTokens        int           // Drop the largest files until the output fits this estimated budget
+	OutputFile       string        // Also write the confirmed output to this file
+	Force            bool          // Overwrite OutputFile without asking
+	Context          string        // Select the files listed in .llmdog/<Context>.txt
+	Select           []string      // Select the files matching these globs
+	FromLog          string        // Select the files referenced as path:line in this log file
+	Signatures       bool          // Include only top-level declarations of supported source files
+	EntryPointsFirst bool          // Put entry point files first in the output
+	ImportGraph      bool          // Add a section showing which selected Go files import which
+	Safe             bool          // Don't read file contents until the output is built
+}
+
+// DefaultConfig returns the configuration used when no config file sets otherwise
+func DefaultConfig() Config {
+	return Config{
+		ShowHiddenFiles:      false,
+		FuzzyThreshold:       0.6,
+		MaxPreviewSize:       10000,
+		ColorTheme:           "default",
+		ContentSearchMode:    false,
+		IncludeEmptyDirs:     false,
+		OutputFormat:         FormatMarkdown,
+		TreeIndent:           "  ",
+		TreeStyle:            TreeStyleASCII,
+		NormalizeLineEndings: false,
+		FlatStructure:        false,
+		ExcludeDirs:          []string{"node_modules", ".git", "vendor", "dist"},
+		ContextCommand:       "",
+		RecursiveDirCounts:   false,
+		CollapsibleFiles:     false,
+		KeyBindings:          map[string]string{},
+		PostCopyCommand:      "",
+		RenderMarkdown:       false,
+		TableOfContents:      false,
+		BookmarkNameTemplate: "{branch}-context",
+		HeaderFormat:         "llmdog • {dir} ({branch})",
+		IncludeBlame:         false,
+		FilesOnly:            false,
+		PreviewLines:         50,
+		ShowParentDirs:       false,
+		StripComments:        false,
+		Preamble:             "",
+		PreambleFile:         "",
+		IncludeExtensions:    []string{},
+		Dedent:               false,
+		SkipFilesLargerThan:  0,
+		RelativePaths:        false,
+		ChangedAsDiff:        false,
+		TokenBadges:          false,
+		SignaturesOnly:       false,
+		ExportIgnore:         false,
+		DockerIgnore:         false,
+		RangeContext:         0,
+		SafeMode:             false,
+		DetectProjectType:    true,
+		ProjectProfiles:      defaultProjectProfiles(),
+		IncludeMetadata:      false,
+		GitMetadata:          []string{},
+		LinkFiles:            false,
+		ClipboardRetries:     2,
+		MaxFileTokens:        0,
+		ReviewBeforeCopy:     false,
+		GroupByDirectory:     false,
+		IncludeStructure:     true,
+		IncludeReadme:        false,
+		HideFileInfo:         false,
+		ModelPrices:          map[string]float64{"gpt-4o": 2.50, "gpt-4o-mini": 0.15, "claude-sonnet": 3.00, "gemini-1.5-pro": 1.25},
+		EntryPointsFirst:     false,
+		EntryPoints:          []string{"main.go", "cmd/*/main.go", "index.js", "index.ts", "main.py", "__main__.py", "main.rs", "Main.java"},
+		ImportGraph:          false,
+	}
 }
 
 // LoadConfig loads configuration from file or creates default
 func LoadConfig() (Config, error) {
-	config := Config{
-		ShowHiddenFiles:   false,
-		FuzzyThreshold:    0.6,
-		MaxPreviewSize:    10000,
-		ColorTheme:        "default",
-		ContentSearchMode: false,
-	}
+	config := DefaultConfig()
 
-	configDir := filepath.Join(os.Getenv("HOME"), ".config", "llmdog")
-	configPath := filepath.Join(configDir, "config.json")
+	configPath := globalConfigPath()
+	configDir := filepath.Dir(configPath)
 
 	data, err := os.ReadFile(configPath)
 	if err != nil {
@@ -69,6 +221,170 @@ func saveConfig(config Config, path string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// InitConfig writes the global config file with every option set to its
+// default and returns its path. An existing file is only replaced with force.
+func InitConfig(force bool) (string, error) {
+	configPath := globalConfigPath()
+	if _, err := os.Stat(configPath); err == nil && !force {
+		return configPath, fmt.Errorf("%s already exists, add --force to overwrite it", configPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return configPath, err
+	}
+	return configPath, saveConfig(DefaultConfig(), configPath)
+}
+
+// ExpandPath expands $VAR and ${VAR} references and a leading ~ in path, so
+// paths given in quotes or in config files work like they do in a shell
+func ExpandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(os.Getenv("HOME"), path[1:])
+	}
+	return path
+}
+
+// globalConfigPath returns the path of the user's global config file
+func globalConfigPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "llmdog", "config.json")
+}
+
+// LastCommand is the most recent non-interactive invocation, kept for --repeat
+type LastCommand struct {
+	Dir  string   `json:"dir"`
+	Args []string `json:"args"`
+}
+
+// lastCommandPath returns the path of the file holding the last non-interactive invocation
+func lastCommandPath() string {
+	return filepath.Join(filepath.Dir(globalConfigPath()), "last-command.json")
+}
+
+// SaveLastCommand records a non-interactive invocation for --repeat
+func SaveLastCommand(command LastCommand) error {
+	data, err := json.MarshalIndent(command, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := lastCommandPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadLastCommand loads the invocation recorded by SaveLastCommand
+func LoadLastCommand() (LastCommand, error) {
+	var command LastCommand
+	data, err := os.ReadFile(lastCommandPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return command, fmt.Errorf("no previous --no-tui run to repeat")
+		}
+		return command, err
+	}
+	err = json.Unmarshal(data, &command)
+	return command, err
+}
+
+// projectConfigNames lists the per-repository config files in lookup order
+var projectConfigNames = []string{".llmdog.yaml", ".llmdog.yml", ".llmdog.json"}
+
+// LoadProjectConfig merges the project config found in dir over config.
+// Only keys present in the project file are overridden. It returns the
+// merged config and the name of the file that was applied, if any.
+func LoadProjectConfig(dir string, config Config) (Config, string, error) {
+	for _, name := range projectConfigNames {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return config, "", err
+		}
+
+		// YAML is converted to JSON so both formats share the JSON keys
+		if ext := filepath.Ext(name); ext == ".yaml" || ext == ".yml" {
+			var raw map[string]interface{}
+			if err := yaml.Unmarshal(data, &raw); err != nil {
+				return config, name, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			data, err = json.Marshal(raw)
+			if err != nil {
+				return config, name, fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+
+		merged := config
+		if err := json.Unmarshal(data, &merged); err != nil {
+			return config, name, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		// A checked-out repository must not be able to run programs on copy
+		// or with --run-command, or paste files from outside it into the output
+		merged.PostCopyCommand = config.PostCopyCommand
+		merged.ContextCommand = config.ContextCommand
+		merged.PreambleFile = config.PreambleFile
+		return merged, name, nil
+	}
+
+	return config, "", nil
+}
+
+// rcConfigName is the per-directory key=value config file
+const rcConfigName = ".llmdogrc"
+
+// LoadRCConfig merges the .llmdogrc found in dir over config. Each line holds
+// key=value with the same keys as the JSON config; values that aren't JSON,
+// like go or dist, are read as strings. Blank lines and lines starting with #
+// are skipped. It reports whether a file was applied.
+func LoadRCConfig(dir string, config Config) (Config, bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, rcConfigName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, false, nil
+		}
+		return config, false, err
+	}
+
+	// Known keys are the JSON keys of the config
+	var known map[string]json.RawMessage
+	encoded, _ := json.Marshal(config)
+	json.Unmarshal(encoded, &known)
+
+	values := make(map[string]json.RawMessage)
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !found || key == "" {
+			return config, true, fmt.Errorf("invalid %s line %d: expected key=value", rcConfigName, n+1)
+		}
+		if _, ok := known[key]; !ok {
+			return config, true, fmt.Errorf("unknown key %q in %s line %d", key, rcConfigName, n+1)
+		}
+		if !json.Valid([]byte(value)) {
+			quoted, _ := json.Marshal(value)
+			value = string(quoted)
+		}
+		values[key] = json.RawMessage(value)
+	}
+
+	encoded, _ = json.Marshal(values)
+	merged := config
+	if err := json.Unmarshal(encoded, &merged); err != nil {
+		return config, true, fmt.Errorf("invalid %s: %w", rcConfigName, err)
+	}
+	// Like project configs, the file may come with a checked-out repository
+	merged.PostCopyCommand = config.PostCopyCommand
+	merged.ContextCommand = config.ContextCommand
+	merged.PreambleFile = config.PreambleFile
+	return merged, true, nil
+}
+
 // Custom messages
 type errMsg struct{ err error }
 type successMsg struct{ message string }
@@ -81,6 +397,14 @@ type customSearchMsg struct {
 	query string
 }
 type resetViewMsg struct{}
+type contentSearchTickMsg struct{ seq int }
+type contentSearchResultMsg struct {
+	seq     int
+	matches map[string]bool
+}
+
+// contentSearchDebounce is how long typing must pause before content is searched
+const contentSearchDebounce = 200 * time.Millisecond
 
 // Model represents the application state
 type Model struct {
@@ -88,6 +412,7 @@ type Model struct {
 	preview             string
 	items               []ui.FileItem
 	cwd                 string
+	singleFile          bool // The root is a single file
 	gitignoreRegexp     *regexp.Regexp
 	termWidth           int
 	termHeight          int
@@ -99,6 +424,8 @@ type Model struct {
 	showErrors          bool
 	searchHistory       []string
 	searchHistoryIndex  int
+	searchMatches       []string
+	searchMatchIndex    int
 	fuzzyThreshold      float64
 	contentSearchMode   bool
 	selectedCount       int
@@ -111,28 +438,143 @@ type Model struct {
 	isInSearchResults   bool
 	bookmarkStore       bookmarks.BookmarkStore
 	showBookmarksMenu   bool
+	showSummary         bool
+	summary             ui.SelectionSummary
+	summaryConfirms     bool // Enter in the summary confirms the selection instead of copying
+	showGitStatus       bool
+	gitStatusMenu       ui.GitStatusMenu
 	bookmarksMenu       ui.BookmarksMenu
 	textInputModal      ui.TextInputModal
 	showTextInputModal  bool
 	textInputPurpose    string
 	tempBookmarkName    string
+	tempMergeNames      []string // Bookmarks to merge once the new name is entered
+	expandDepth         int      // Depth folders were last expanded to with D
+	clipboardFailed     bool     // The last output couldn't be copied to the clipboard
+	extensionDeselect   bool     // The extension dialog deselects rather than selects
+	tempRangePath       string
+	tempNotePath        string
+	projectProfiles     []string     // Detected project types whose exclusions apply
+	header              string       // Title shown above the list, from the header format
+	rangePicker         *rangePicker // Set while picking a line range in the preview pane
+	lastOutput          string
+	lastItems           []ui.FileItem
+	lastStats           outputStats // What building the last output left out
+	keys                keyRemap
+	confirmed           bool
+	renderMarkdown      bool
+	treePreview         bool
+	selectedOnly        bool // The list shows only the selection and the folders leading to it
+	skippedLarge        map[string]bool
+	outputFile          string
+	forceOutput         bool
+	maxTokens           int
+	droppedFiles        []string
+	contentSearchSeq    int
+	contentScan         *contentScan // Content search running in the background, if any
 }
 
 // New creates a new model
-func New() *Model {
+func New(opts Options) *Model {
 	cwd, err := os.Getwd()
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// An explicit root replaces the working directory; a single file is
+	// shown on its own with its directory acting as the root
+	root := cwd
+	singleFile := false
+	if opts.Root != "" {
+		root, err = filepath.Abs(opts.Root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		singleFile = !info.IsDir()
+		cwd = root
+		if singleFile {
+			cwd = filepath.Dir(root)
+		}
 	}
 
 	// Load config
 	config, err := LoadConfig()
 	if err != nil {
-		log.Printf("Warning: Could not load config: %v", err)
+		slog.Warn("could not load config", "path", globalConfigPath(), "err", err)
+	}
+
+	// Project config overrides the global config for this repository
+	config, projectConfigName, err := LoadProjectConfig(cwd, config)
+	if err != nil {
+		slog.Warn("could not load project config", "dir", cwd, "err", err)
+	} else if projectConfigName != "" {
+		slog.Info("loaded project config", "file", projectConfigName)
+	}
+
+	// A .llmdogrc in the directory overrides both for quick local tweaks
+	config, loadedRC, err := LoadRCConfig(cwd, config)
+	if err != nil {
+		slog.Warn("could not load "+rcConfigName, "dir", cwd, "err", err)
+	} else if loadedRC {
+		slog.Info("loaded " + rcConfigName)
+	}
+
+	// Command-line flags override all config files
+	if opts.Format != "" {
+		config.OutputFormat = opts.Format
+	}
+	config.RunContextCommand = opts.RunCommand
+	if opts.Signatures {
+		config.SignaturesOnly = true
+	}
+	if opts.EntryPointsFirst {
+		config.EntryPointsFirst = true
+	}
+	if opts.ImportGraph {
+		config.ImportGraph = true
+	}
+	if opts.Safe {
+		config.SafeMode = true
+	}
+	profiles := applyProjectProfiles(&config, cwd)
+	if len(profiles) > 0 {
+		slog.Debug("detected project type", "profiles", profiles, "excludeDirs", config.ExcludeDirs)
+	}
+
+	gitRegex, gitignoreErr := git.ParseGitignore(filepath.Join(cwd, ".gitignore"))
+	if gitignoreErr != nil && os.IsNotExist(gitignoreErr) {
+		gitignoreErr = nil
+	}
+	if config.ExportIgnore {
+		rules, err := git.ParseExportIgnoreRules(filepath.Join(cwd, ".gitattributes"))
+		gitRegex, gitignoreErr = addIgnoreRules(gitRegex, gitignoreErr, rules, err, "export-ignore")
+	}
+	if config.DockerIgnore {
+		rules, err := git.ParseGitignoreRules(filepath.Join(cwd, ".dockerignore"))
+		gitRegex, gitignoreErr = addIgnoreRules(gitRegex, gitignoreErr, rules, err, ".dockerignore")
+	}
+	if gitignoreErr != nil {
+		slog.Warn("gitignore parse failed", "err", gitignoreErr)
+	}
+	items, unreadable := ui.LoadFiles(root, newLoadOptions(config, gitRegex))
+	slog.Debug("loaded files", "root", root, "count", len(items))
+	if len(unreadable) > 0 {
+		slog.Warn("skipped unreadable paths", "paths", unreadable)
 	}
 
-	gitRegex, _ := git.ParseGitignore(filepath.Join(cwd, ".gitignore"))
-	items := ui.LoadFiles(cwd, gitRegex, config.ShowHiddenFiles)
+	// A single file root is selected right away
+	if singleFile {
+		for i := range items {
+			items[i].Selected = true
+		}
+	}
 
 	// Only include top-level items initially since folders are collapsed
 	var listItems []list.Item
@@ -142,38 +584,173 @@ func New() *Model {
 		}
 	}
 
-	l := list.New(listItems, ui.ItemDelegate{}, 30, 14)
+	l := list.New(listItems, newItemDelegate(config, cwd), 30, 14)
 	l.Title = " Files  |  ↑↓:navigate  •  Space:expand/collapse folder •  Tab:select  •  /:filter  •  Enter:confirm  •  q:quit "
 	l.SetFilteringEnabled(true)
 
+	// Custom key bindings, falling back to the defaults if they conflict
+	keys, keysErr := newKeyRemap(config.KeyBindings)
+	if keysErr != nil {
+		slog.Warn("ignoring key bindings", "err", keysErr)
+		keys = keyRemap{}
+	}
+	// The list handles filtering and quitting itself
+	l.KeyMap.Filter.SetKeys(keys.boundKey("filter"))
+	l.KeyMap.Quit.SetKeys(keys.boundKey("quit"), "esc")
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 
 	bookmarkStore, err := bookmarks.LoadBookmarks()
 	if err != nil {
-		log.Printf("Warning: Could not load bookmarks: %v", err)
+		slog.Warn("could not load bookmarks", "err", err)
 	}
 
-	return &Model{
+	m := &Model{
 		list:               l,
 		items:              items,
 		cwd:                cwd,
+		singleFile:         singleFile,
 		gitignoreRegexp:    gitRegex,
+		projectProfiles:    profiles,
+		header:             headerTitle(config.HeaderFormat, cwd),
 		showPreview:        true,
 		spinner:            s,
 		fuzzyThreshold:     config.FuzzyThreshold,
-		contentSearchMode:  config.ContentSearchMode,
+		contentSearchMode:  config.ContentSearchMode && !config.SafeMode,
 		config:             config,
 		bookmarkStore:      bookmarkStore,
 		showBookmarksMenu:  false,
 		showTextInputModal: false,
+		keys:               keys,
+		renderMarkdown:     config.RenderMarkdown,
+		maxTokens:          opts.MaxTokens,
+		outputFile:         opts.OutputFile,
+		forceOutput:        opts.Force,
+	}
+
+	if projectConfigName != "" {
+		m.setStatusMessage(fmt.Sprintf("Loaded project config: %s", projectConfigName), 2)
+	}
+
+	if singleFile {
+		m.refreshSelectionStats()
+		m.setStatusMessage(fmt.Sprintf("Opened single file: %s (press Enter to copy)", filepath.Base(root)), 3)
+	}
+
+	if keysErr != nil {
+		m.addError(fmt.Errorf("Warning: %v", keysErr))
+	}
+
+	// Surface gitignore problems instead of silently filtering nothing
+	if gitignoreErr != nil {
+		m.addError(fmt.Errorf("Warning: %v", gitignoreErr))
+	}
+
+	// Directories we can't read would otherwise just be missing from the tree
+	if len(unreadable) > 0 {
+		m.addError(fmt.Errorf("Warning: skipped %s", summarizePaths(unreadable, cwd, "unreadable path")))
+	}
+
+	if opts.RunCommand && config.ContextCommand == "" {
+		m.addError(fmt.Errorf("Warning: --run-command given but no contextCommand is configured"))
+	}
+
+	if opts.ModifiedWithin > 0 {
+		m.selectModifiedWithin(opts.ModifiedWithin)
+	}
+
+	if opts.Context != "" {
+		m.applyContext(opts.Context)
+	}
+
+	if len(opts.Select) > 0 {
+		m.applySelectPatterns(opts.Select)
+	}
+
+	if opts.FromLog != "" {
+		m.selectFromLog(opts.FromLog)
+	}
+
+	return m
+}
+
+// summarizePaths describes a list of paths relative to cwd, naming the first
+// few and counting the rest
+func summarizePaths(paths []string, cwd, noun string) string {
+	const maxNamed = 3
+
+	var names []string
+	for i, path := range paths {
+		if i == maxNamed {
+			names = append(names, fmt.Sprintf("and %d more", len(paths)-maxNamed))
+			break
+		}
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			path = rel
+		}
+		names = append(names, path)
+	}
+
+	if len(paths) != 1 {
+		noun += "s"
+	}
+	return fmt.Sprintf("%d %s: %s", len(paths), noun, strings.Join(names, ", "))
+}
+
+// newItemDelegate builds the file list renderer from the config
+func newItemDelegate(config Config, cwd string) ui.ItemDelegate {
+	return ui.ItemDelegate{
+		RecursiveCounts: config.RecursiveDirCounts,
+		ExcludeDirs:     config.ExcludeDirs,
+		RelativePaths:   config.RelativePaths,
+		TokenBadges:     config.TokenBadges,
+		HideFileInfo:    config.HideFileInfo,
+		Root:            cwd,
+	}
+}
+
+// addIgnoreRules extends the gitignore regexp with rules from another source,
+// such as export-ignore attributes or a .dockerignore, so the files they match
+// are filtered like gitignored ones. A missing source file is not an error.
+func addIgnoreRules(gitRegex *regexp.Regexp, gitignoreErr error, rules []git.GitignoreRule, err error, source string) (*regexp.Regexp, error) {
+	if err != nil && !os.IsNotExist(err) {
+		gitignoreErr = errors.Join(gitignoreErr, err)
+	}
+	if len(rules) == 0 {
+		return gitRegex, gitignoreErr
+	}
+	if gitRegex != nil {
+		rules = append(rules, git.GitignoreRule{Regexp: gitRegex})
+	}
+	combined, err := git.CompileRules(rules)
+	if err != nil {
+		return gitRegex, errors.Join(gitignoreErr, fmt.Errorf("failed to compile %s rules: %w", source, err))
 	}
+	return combined, gitignoreErr
+}
+
+// newLoadOptions builds the file tree loading options from the config
+func newLoadOptions(config Config, gitRegex *regexp.Regexp) ui.LoadOptions {
+	return ui.LoadOptions{
+		GitRegex:          gitRegex,
+		ShowHidden:        config.ShowHiddenFiles,
+		ExcludeDirs:       config.ExcludeDirs,
+		IncludeExtensions: config.IncludeExtensions,
+		GitMetadata:       config.GitMetadata,
+	}
+}
+
+// loadOptions returns the file tree loading options for the current session
+func (m *Model) loadOptions() ui.LoadOptions {
+	return newLoadOptions(m.config, m.gitignoreRegexp)
 }
 
 // addError adds an error to the error list
 func (m *Model) addError(err error) {
 	if err != nil {
+		slog.Error("error shown to user", "err", err)
 		m.errors = append(m.errors, err.Error())
 		m.showErrors = true
 	}
@@ -247,8 +824,19 @@ func (m *Model) refreshVisibleItems() {
 		}
 	}
 
+	// Reviewing the selection shows it whether or not its folders are expanded
+	var shown map[string]bool
+	if m.selectedOnly {
+		shown = make(map[string]bool)
+		for path := range selectedItems {
+			for dir := path; strings.HasPrefix(dir, m.cwd) && !shown[dir]; dir = filepath.Dir(dir) {
+				shown[dir] = true
+			}
+		}
+	}
+
 	for i := range m.items {
-		if m.isVisible(m.items[i]) {
+		if (m.selectedOnly && shown[m.items[i].Path]) || (!m.selectedOnly && m.isVisible(m.items[i])) {
 			// Ensure selection state is preserved
 			if _, ok := selectedItems[m.items[i].Path]; ok {
 				m.items[i].Selected = true
@@ -291,8 +879,7 @@ func (m *Model) refreshSelectionStats() {
 				m.selectedSize += info.Size()
 
 				// Estimate tokens (very rough approximation)
-				// Assuming 4 characters per token on average
-				m.estimatedTokens += int(info.Size()) / 4
+				m.estimatedTokens += tokens.EstimateSize(info.Size())
 			}
 		}
 	}
@@ -333,18 +920,69 @@ func (m *Model) areAllDescendantsSelected(parentPath string) bool {
 	return true
 }
 
+// isAnyDescendantSelected checks if at least one descendant is selected
+func (m *Model) isAnyDescendantSelected(parentPath string) bool {
+	for _, desc := range m.getAllDescendants(parentPath) {
+		if desc.Selected {
+			return true
+		}
+	}
+	return false
+}
+
 // setSelectionStateForDescendants sets selection state for all descendants
 func (m *Model) setSelectionStateForDescendants(parentPath string, selected bool) {
 	// Update all descendants
 	for i := range m.items {
 		if strings.HasPrefix(m.items[i].Path, parentPath+string(os.PathSeparator)) {
+			if selected && m.skipsLargeFile(m.items[i]) {
+				continue
+			}
 			if !m.isGitIgnored(m.items[i].Path) {
 				m.items[i].Selected = selected
+				m.items[i].PartiallySelected = false
+				if !selected {
+					m.items[i].Note = ""
+				}
 			}
 		}
 	}
 }
 
+// skipsLargeFile reports whether a bulk selection should leave out item for
+// being larger than Config.SkipFilesLargerThan, remembering it if so
+func (m *Model) skipsLargeFile(item ui.FileItem) bool {
+	if !m.isLargeFile(item) {
+		return false
+	}
+	if m.skippedLarge == nil {
+		m.skippedLarge = make(map[string]bool)
+	}
+	m.skippedLarge[item.Path] = true
+	return true
+}
+
+// isLargeFile reports whether item is larger than Config.SkipFilesLargerThan,
+// without remembering it for skippedLargeNote
+func (m *Model) isLargeFile(item ui.FileItem) bool {
+	if m.config.SkipFilesLargerThan <= 0 || item.IsDir {
+		return false
+	}
+	info, err := os.Stat(item.Path)
+	return err == nil && info.Size() > m.config.SkipFilesLargerThan
+}
+
+// skippedLargeNote describes the files the last bulk selection skipped for
+// their size, and resets the list for the next one
+func (m *Model) skippedLargeNote() string {
+	if len(m.skippedLarge) == 0 {
+		return ""
+	}
+	note := fmt.Sprintf(", skipped %d files larger than %.1f KB", len(m.skippedLarge), float64(m.config.SkipFilesLargerThan)/1024)
+	m.skippedLarge = nil
+	return note
+}
+
 // updateParentSelectionState updates a parent's selection state based on children
 func (m *Model) updateParentSelectionState(childPath string) {
 	parentPath := filepath.Dir(childPath)
@@ -355,6 +993,7 @@ func (m *Model) updateParentSelectionState(childPath string) {
 	for i := range m.items {
 		if m.items[i].Path == parentPath && m.items[i].IsDir {
 			m.items[i].Selected = m.areAllDescendantsSelected(parentPath)
+			m.items[i].PartiallySelected = !m.items[i].Selected && m.isAnyDescendantSelected(parentPath)
 			// Recursively update parent directories
 			m.updateParentSelectionState(parentPath)
 			break
@@ -380,7 +1019,7 @@ func (m *Model) toggleExpansion(path string) tea.Cmd {
 
 					// Return a command instead of using a goroutine directly
 					cmds = append(cmds, func() tea.Msg {
-						children, err := ui.LoadDirectoryChildren(path, m.gitignoreRegexp, m.config.ShowHiddenFiles)
+						children, err := ui.LoadDirectoryChildren(path, m.loadOptions())
 						if err != nil {
 							return errMsg{err}
 						}
@@ -432,10 +1071,12 @@ func (m *Model) toggleSelection(path string, forceSelect ...bool) {
 		if (currentItem.Selected && !force) || (force && !forceValue) {
 			// Unselect directory and all descendants
 			currentItem.Selected = false
+			currentItem.PartiallySelected = false
 			m.setSelectionStateForDescendants(currentItem.Path, false)
 		} else {
 			// Select directory and all non-gitignored descendants
 			currentItem.Selected = true
+			currentItem.PartiallySelected = false
 			m.setSelectionStateForDescendants(currentItem.Path, true)
 		}
 	} else {
@@ -445,6 +1086,10 @@ func (m *Model) toggleSelection(path string, forceSelect ...bool) {
 		} else {
 			currentItem.Selected = !currentItem.Selected
 		}
+		// Notes only make sense for selected files
+		if !currentItem.Selected {
+			currentItem.Note = ""
+		}
 	}
 
 	// Update parent directory selection states
@@ -452,10 +1097,12 @@ func (m *Model) toggleSelection(path string, forceSelect ...bool) {
 	m.refreshVisibleItems()
 }
 
-// performSearch executes a search based on current search mode
-func (m *Model) performSearch(query string) {
+// performSearch shows the items whose name contains query, along with those
+// in contentMatches
+func (m *Model) performSearch(query string, contentMatches map[string]bool) {
 	// If no query, show all visible items
 	if query == "" {
+		m.searchMatches = nil
 		m.refreshVisibleItems()
 		return
 	}
@@ -480,17 +1127,10 @@ func (m *Model) performSearch(query string) {
 			matched = true
 		}
 
-		// Content search if enabled and not a directory
-		if !matched && m.contentSearchMode && !m.items[i].IsDir {
-			// Only attempt to read small files to avoid performance issues
-			info, err := os.Stat(m.items[i].Path)
-			if err == nil && info.Size() < 1024*1024 { // Skip files larger than 1MB
-				content, err := os.ReadFile(m.items[i].Path)
-				if err == nil && strings.Contains(strings.ToLower(string(content)), queryLower) {
-					matched = true
-					m.items[i].MatchesContent = true // Flag for UI highlight
-				}
-			}
+		// Content matches were found by searchAll
+		if !matched && contentMatches[m.items[i].Path] {
+			matched = true
+			m.items[i].MatchesContent = true // Flag for UI highlight
 		}
 
 		if matched {
@@ -500,11 +1140,21 @@ func (m *Model) performSearch(query string) {
 			// Make sure all parent directories are expanded to make this item visible
 			m.ensureParentPathsExpanded(m.items[i].Path)
 
-			// Add this item to results
-			results = append(results, m.items[i])
+			// Add this item to results, remembering which characters of the name matched
+			result := m.items[i]
+			result.NameMatches = ui.SubstringMatches(result.Name, query)
+			results = append(results, result)
 		}
 	}
 
+	// Remember the matched items themselves for n/N navigation
+	m.searchMatches = m.searchMatches[:0]
+	for path := range foundPaths {
+		m.searchMatches = append(m.searchMatches, path)
+	}
+	sort.Strings(m.searchMatches)
+	m.searchMatchIndex = -1
+
 	// Now add all necessary parent directories to make the hierarchy visible
 	for i := range m.items {
 		if foundPaths[m.items[i].Path] {
@@ -539,7 +1189,7 @@ func (m *Model) performSearch(query string) {
 	if len(results) > 0 {
 		m.list.SetItems(results)
 		// Set status message with count
-		m.setStatusMessage(fmt.Sprintf("Found %d matches", len(foundPaths)), 2)
+		m.setStatusMessage(fmt.Sprintf("Found %d matches (n/N to jump)", len(m.searchMatches)), 2)
 	} else if m.contentSearchMode {
 		// If no results with content search, show a message
 		m.setStatusMessage("No matches found. Try different search terms.", 2)
@@ -551,201 +1201,1819 @@ func (m *Model) performSearch(query string) {
 	}
 }
 
-// ensureParentPathsExpanded makes sure all parent directories of a path are expanded
-func (m *Model) ensureParentPathsExpanded(path string) {
-	dir := filepath.Dir(path)
-
-	// If we've reached the root, stop
-	if dir == m.cwd || dir == "." {
+// jumpToSearchMatch moves the cursor to the next (step 1) or previous (step -1) search match
+func (m *Model) jumpToSearchMatch(step int) {
+	if len(m.searchMatches) == 0 {
+		m.setStatusMessage("No search matches", 2)
 		return
 	}
 
-	// Recursively process parent directories first
-	m.ensureParentPathsExpanded(dir)
-
-	// Then expand this directory
-	for i := range m.items {
-		if m.items[i].Path == dir && m.items[i].IsDir {
-			// Ensure this directory is expanded
-			m.items[i].Expanded = true
-
-			// If children aren't loaded yet, load them synchronously
-			if !m.items[i].ChildrenLoaded {
-				children, err := ui.LoadDirectoryChildren(dir, m.gitignoreRegexp, m.config.ShowHiddenFiles)
-				if err == nil {
-					// Check for duplicates before adding
-					existingPaths := make(map[string]bool)
-					for _, item := range m.items {
-						existingPaths[item.Path] = true
-					}
-
-					for _, child := range children {
-						if !existingPaths[child.Path] {
-							m.items = append(m.items, child)
-						}
-					}
+	count := len(m.searchMatches)
+	if m.searchMatchIndex < 0 && step < 0 {
+		m.searchMatchIndex = 0
+	}
+	m.searchMatchIndex = ((m.searchMatchIndex+step)%count + count) % count
 
-					m.items[i].ChildrenLoaded = true
-				}
-			}
+	target := m.searchMatches[m.searchMatchIndex]
+	for i, item := range m.list.Items() {
+		if fileItem, ok := item.(ui.FileItem); ok && fileItem.Path == target {
+			m.list.Select(i)
+			m.preview = m.loadPreview(fileItem)
 			break
 		}
 	}
-}
 
-// ensureParentDirsExpanded ensures all parent directories are expanded
-// and adds them to results for display
-func (m *Model) ensureParentDirsExpanded(path string, results *[]list.Item, foundPaths *map[string]bool) {
-	parentPath := filepath.Dir(path)
-	if parentPath == m.cwd || parentPath == "." {
-		return
-	}
+	m.setStatusMessage(fmt.Sprintf("Match %d/%d", m.searchMatchIndex+1, count), 2)
+}
 
-	// Recursively process parents first
-	m.ensureParentDirsExpanded(parentPath, results, foundPaths)
+// showGoToPathDialog asks for a path to jump to
+func (m *Model) showGoToPathDialog() {
+	m.textInputModal = ui.NewTextInputModal(
+		"Go to Path (Tab completes)",
+		"internal/model/model.go",
+		m.termWidth/2,
+	)
+	m.showTextInputModal = true
+	m.textInputPurpose = "goto_path"
+}
 
-	// Then add this parent if not already included
-	if !(*foundPaths)[parentPath] {
-		for i := range m.items {
-			if m.items[i].Path == parentPath && m.items[i].IsDir {
-				// Mark directory as expanded
-				m.items[i].Expanded = true
+// goToPath expands the parents of a path relative to the working directory
+// and moves the cursor to it
+func (m *Model) goToPath(input string) error {
+	path := filepath.Join(m.cwd, filepath.FromSlash(strings.TrimSuffix(input, "/")))
 
-				// Add it to results if not already there
-				(*foundPaths)[parentPath] = true
-				*results = append(*results, m.items[i])
-				break
-			}
+	found := false
+	for _, item := range m.items {
+		if item.Path == path {
+			found = true
+			break
 		}
 	}
-}
-
-// selectAll selects all visible items
-func (m *Model) selectAll() {
-	for _, item := range m.list.Items() {
-		if fileItem, ok := item.(ui.FileItem); ok && !m.isGitIgnored(fileItem.Path) {
-			m.toggleSelection(fileItem.Path, true)
-		}
+	if !found {
+		return fmt.Errorf("not in the file tree: %s", input)
 	}
-}
 
-// deselectAll deselects all items
-func (m *Model) deselectAll() {
-	for i := range m.items {
-		m.items[i].Selected = false
-	}
+	m.ensureParentPathsExpanded(path)
 	m.refreshVisibleItems()
-}
-
-// selectByExtension selects all items with given extension
-func (m *Model) selectByExtension(ext string) {
-	// Ensure extension has a dot prefix
-	if !strings.HasPrefix(ext, ".") {
-		ext = "." + ext
-	}
 
-	for i := range m.items {
-		if !m.items[i].IsDir && strings.HasSuffix(strings.ToLower(m.items[i].Path), strings.ToLower(ext)) {
-			m.toggleSelection(m.items[i].Path, true)
+	for i, item := range m.list.Items() {
+		if fileItem, ok := item.(ui.FileItem); ok && fileItem.Path == path {
+			m.list.Select(i)
+			m.preview = m.loadPreview(fileItem)
+			break
 		}
 	}
+	return nil
 }
 
-// toggleContentSearchMode toggles content search mode
-func (m *Model) toggleContentSearchMode() {
-	m.contentSearchMode = !m.contentSearchMode
-	m.config.ContentSearchMode = m.contentSearchMode
-	saveConfig(m.config, filepath.Join(os.Getenv("HOME"), ".config", "llmdog", "config.json"))
+// completeGoToPath completes the next path segment in the go to path dialog,
+// listing the options when more than one path matches
+func (m *Model) completeGoToPath() {
+	input := filepath.ToSlash(m.textInputModal.Value())
 
-	if m.contentSearchMode {
+	// Each candidate is the input extended to the end of its next segment
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, item := range m.items {
+		rel, err := filepath.Rel(m.cwd, item.Path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if item.IsDir {
+			rel += "/"
+		}
+		if !strings.HasPrefix(rel, input) || rel == input {
+			continue
+		}
+		candidate := rel
+		if slash := strings.Index(rel[len(input):], "/"); slash >= 0 {
+			candidate = rel[:len(input)+slash+1]
+		}
+		if !seen[candidate] {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	if len(candidates) == 0 {
+		m.textInputModal.SetMessage("No matching paths")
+		return
+	}
+
+	completed := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, completed) {
+			completed = completed[:len(completed)-1]
+		}
+	}
+	m.textInputModal.SetValue(completed)
+
+	if len(candidates) == 1 {
+		m.textInputModal.SetMessage("")
+		return
+	}
+	sort.Strings(candidates)
+	const maxShown = 8
+	options := candidates
+	if len(options) > maxShown {
+		options = append(options[:maxShown:maxShown], fmt.Sprintf("... and %d more", len(candidates)-maxShown))
+	}
+	m.textInputModal.SetMessage(strings.Join(options, "\n"))
+}
+
+// startLiveContentSearch searches the content of the listed files in the
+// background. Starting a new search cancels the one in flight.
+func (m *Model) startLiveContentSearch(seq int, query string) tea.Cmd {
+	if query == "" {
+		return nil
+	}
+
+	var paths []string
+	for _, item := range m.list.Items() {
+		if fileItem, ok := item.(ui.FileItem); ok && !fileItem.IsDir {
+			paths = append(paths, fileItem.Path)
+		}
+	}
+	scan := m.startContentScan(len(paths))
+	queryLower := strings.ToLower(query)
+
+	return tea.Batch(func() tea.Msg {
+		matches := scan.run(paths, queryLower)
+		if matches == nil {
+			return nil
+		}
+		return contentSearchResultMsg{seq: seq, matches: matches}
+	}, contentSearchProgressTick(seq))
+}
+
+// applyLiveContentSearch flags the content matches and extends the list's
+// filter so they are shown next to the name matches
+func (m *Model) applyLiveContentSearch(matches map[string]bool) tea.Cmd {
+	items := m.list.Items()
+	for i, item := range items {
+		if fileItem, ok := item.(ui.FileItem); ok {
+			fileItem.MatchesContent = matches[fileItem.Path]
+			items[i] = fileItem
+		}
+	}
+
+	m.list.Filter = func(term string, targets []string) []list.Rank {
+		ranks := list.DefaultFilter(term, targets)
+		found := make(map[int]bool)
+		for _, rank := range ranks {
+			found[rank.Index] = true
+		}
+		for i, item := range items {
+			if fileItem, ok := item.(ui.FileItem); ok && fileItem.MatchesContent && !found[i] && i < len(targets) {
+				ranks = append(ranks, list.Rank{Index: i})
+			}
+		}
+		return ranks
+	}
+
+	m.setStatusMessage(fmt.Sprintf("%d content matches", len(matches)), 2)
+	return m.list.SetItems(items)
+}
+
+// stopLiveContentSearch cancels a running content search and goes back to
+// filtering by name only
+func (m *Model) stopLiveContentSearch() {
+	if m.contentScan != nil {
+		m.contentScan.cancel()
+		m.contentScan = nil
+	}
+	m.list.Filter = list.DefaultFilter
+}
+
+// ensureParentPathsExpanded makes sure all parent directories of a path are expanded
+func (m *Model) ensureParentPathsExpanded(path string) {
+	dir := filepath.Dir(path)
+
+	// If we've reached the root, stop
+	if dir == m.cwd || dir == "." {
+		return
+	}
+
+	// Recursively process parent directories first
+	m.ensureParentPathsExpanded(dir)
+
+	// Then expand this directory
+	for i := range m.items {
+		if m.items[i].Path == dir && m.items[i].IsDir {
+			// Ensure this directory is expanded
+			m.items[i].Expanded = true
+			m.loadChildrenNow(i)
+			break
+		}
+	}
+}
+
+// loadChildrenNow synchronously loads the children of the directory at
+// m.items[i] if they aren't loaded yet
+func (m *Model) loadChildrenNow(i int) {
+	if m.items[i].ChildrenLoaded {
+		return
+	}
+
+	children, err := ui.LoadDirectoryChildren(m.items[i].Path, m.loadOptions())
+	if err != nil {
+		return
+	}
+
+	// Check for duplicates before adding
+	existingPaths := make(map[string]bool)
+	for _, item := range m.items {
+		existingPaths[item.Path] = true
+	}
+
+	for _, child := range children {
+		if !existingPaths[child.Path] {
+			m.items = append(m.items, child)
+		}
+	}
+
+	m.items[i].ChildrenLoaded = true
+}
+
+// maxExpandDepth is the deepest level D cycles through before collapsing again
+const maxExpandDepth = 3
+
+// cycleExpandDepth expands folders one level deeper on each call, up to
+// maxExpandDepth, then collapses everything
+func (m *Model) cycleExpandDepth() {
+	m.expandDepth = (m.expandDepth + 1) % (maxExpandDepth + 1)
+	m.expandToDepth(m.expandDepth)
+
+	if m.expandDepth == 0 {
+		m.setStatusMessage("Collapsed all folders", 2)
+	} else {
+		m.setStatusMessage(fmt.Sprintf("Expanded folders to depth %d", m.expandDepth), 2)
+	}
+}
+
+// expandToDepth expands the folders less than depth levels deep and collapses
+// the rest. Gitignored folders stay collapsed.
+func (m *Model) expandToDepth(depth int) {
+	// Loading children grows m.items, so the length is checked on every pass
+	for i := 0; i < len(m.items); i++ {
+		if !m.items[i].IsDir {
+			continue
+		}
+		m.items[i].Expanded = m.items[i].Depth < depth && !m.items[i].GitIgnored
+		if m.items[i].Expanded {
+			m.loadChildrenNow(i)
+		}
+	}
+	m.refreshVisibleItems()
+}
+
+// ensureParentDirsExpanded ensures all parent directories are expanded
+// and adds them to results for display
+func (m *Model) ensureParentDirsExpanded(path string, results *[]list.Item, foundPaths *map[string]bool) {
+	parentPath := filepath.Dir(path)
+	if parentPath == m.cwd || parentPath == "." {
+		return
+	}
+
+	// Recursively process parents first
+	m.ensureParentDirsExpanded(parentPath, results, foundPaths)
+
+	// Then add this parent if not already included
+	if !(*foundPaths)[parentPath] {
+		for i := range m.items {
+			if m.items[i].Path == parentPath && m.items[i].IsDir {
+				// Mark directory as expanded
+				m.items[i].Expanded = true
+
+				// Add it to results if not already there
+				(*foundPaths)[parentPath] = true
+				*results = append(*results, m.items[i])
+				break
+			}
+		}
+	}
+}
+
+// selectAll selects all visible items
+func (m *Model) selectAll() {
+	for _, item := range m.list.Items() {
+		if fileItem, ok := item.(ui.FileItem); ok && !m.isGitIgnored(fileItem.Path) && !m.skipsLargeFile(fileItem) {
+			m.toggleSelection(fileItem.Path, true)
+		}
+	}
+}
+
+// selectAllBreakdown summarises what selectAll would grab from the visible
+// items, as one line per extension ordered by size plus a total
+func (m *Model) selectAllBreakdown() (string, int) {
+	type extStats struct {
+		ext   string
+		count int
+		size  int64
+	}
+	byExt := make(map[string]*extStats)
+	seen := make(map[string]bool)
+	var totalSize int64
+	total := 0
+
+	addFile := func(item ui.FileItem) {
+		// Like selectAll, leave out the files skipFilesLargerThan skips
+		if item.IsDir || item.GitIgnored || seen[item.Path] || m.isLargeFile(item) {
+			return
+		}
+		seen[item.Path] = true
+
+		ext := strings.ToLower(filepath.Ext(item.Name))
+		if ext == "" {
+			ext = "(none)"
+		}
+		stats, ok := byExt[ext]
+		if !ok {
+			stats = &extStats{ext: ext}
+			byExt[ext] = stats
+		}
+		if info, err := os.Stat(item.Path); err == nil {
+			stats.size += info.Size()
+			totalSize += info.Size()
+		}
+		stats.count++
+		total++
+	}
+
+	for _, listItem := range m.list.Items() {
+		fileItem, ok := listItem.(ui.FileItem)
+		if !ok || m.isGitIgnored(fileItem.Path) {
+			continue
+		}
+		if fileItem.IsDir {
+			for _, descendant := range m.getAllDescendants(fileItem.Path) {
+				addFile(descendant)
+			}
+		} else {
+			addFile(fileItem)
+		}
+	}
+
+	var sorted []*extStats
+	for _, stats := range byExt {
+		sorted = append(sorted, stats)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].size != sorted[j].size {
+			return sorted[i].size > sorted[j].size
+		}
+		return sorted[i].ext < sorted[j].ext
+	})
+
+	var lines []string
+	for _, stats := range sorted {
+		lines = append(lines, fmt.Sprintf("%-10s %5d files  %10.1f KB", stats.ext, stats.count, float64(stats.size)/1024))
+	}
+	lines = append(lines, "", fmt.Sprintf("Total: %d files (%.1f KB) • Est. Tokens: ~%d",
+		total, float64(totalSize)/1024, tokens.EstimateSize(totalSize)))
+
+	return strings.Join(lines, "\n"), total
+}
+
+// showSelectAllDialog asks for confirmation before selecting all visible items
+func (m *Model) showSelectAllDialog() {
+	breakdown, total := m.selectAllBreakdown()
+	if total == 0 {
+		m.setStatusMessage("Nothing to select", 2)
+		return
+	}
+
+	m.textInputModal = ui.NewTextInputModal(
+		fmt.Sprintf("Select all %d files? (y/n)", total),
+		"y",
+		m.termWidth/2,
+	)
+	m.textInputModal.SetMessage(breakdown)
+	m.showTextInputModal = true
+	m.textInputPurpose = "select_all"
+}
+
+// deselectAll deselects all items
+func (m *Model) deselectAll() {
+	for i := range m.items {
+		m.items[i].Selected = false
+		m.items[i].PartiallySelected = false
+		m.items[i].Note = ""
+	}
+	m.refreshVisibleItems()
+}
+
+// selectByExtension selects or deselects all items with given extension, or
+// any other file name suffix such as "_test.go". It returns how many items
+// were changed.
+func (m *Model) selectByExtension(ext string, selected bool) int {
+	// A bare extension like "go" gets its dot
+	if !strings.Contains(ext, ".") {
+		ext = "." + ext
+	}
+
+	count := 0
+	for i := range m.items {
+		if !m.items[i].IsDir && !m.items[i].GitIgnored && strings.HasSuffix(strings.ToLower(m.items[i].Path), strings.ToLower(ext)) {
+			if selected && m.skipsLargeFile(m.items[i]) {
+				continue
+			}
+			if !selected && !m.items[i].Selected {
+				continue
+			}
+			m.toggleSelection(m.items[i].Path, selected)
+			count++
+		}
+	}
+	return count
+}
+
+// showExtensionDialog asks for an extension or suffix to select or deselect.
+// Tab switches between the two.
+func (m *Model) showExtensionDialog(deselect bool) {
+	value := ""
+	if m.showTextInputModal && m.textInputPurpose == "by_extension" {
+		value = m.textInputModal.Value()
+	}
+
+	title := "Select Files by Extension or Suffix (Tab: deselect instead)"
+	if deselect {
+		title = "Deselect Files by Extension or Suffix (Tab: select instead)"
+	}
+	m.extensionDeselect = deselect
+	m.textInputModal = ui.NewTextInputModal(title, "_test.go", m.termWidth/2)
+	m.textInputModal.SetValue(value)
+	m.showTextInputModal = true
+	m.textInputPurpose = "by_extension"
+}
+
+// toggleSameExtension selects every file sharing the highlighted file's
+// extension, or deselects them all if they are already selected
+func (m *Model) toggleSameExtension(item ui.FileItem) {
+	ext := filepath.Ext(item.Name)
+	if item.IsDir || ext == "" {
+		m.setStatusMessage("Highlighted item has no file extension", 2)
+		return
+	}
+
+	// Files a selection would skip for their size don't count as unselected
+	allSelected := true
+	for _, other := range m.items {
+		if !other.IsDir && !other.GitIgnored && strings.EqualFold(filepath.Ext(other.Name), ext) && !other.Selected && !m.isLargeFile(other) {
+			allSelected = false
+			break
+		}
+	}
+
+	count := m.selectByExtension(ext, !allSelected)
+	if allSelected {
+		m.setStatusMessage(fmt.Sprintf("Deselected %d %s files", count, ext), 2)
+	} else {
+		m.setStatusMessage(fmt.Sprintf("Selected %d %s files%s", count, ext, m.skippedLargeNote()), 2)
+	}
+}
+
+// selectModifiedWithin selects all files modified within the given window
+// and expands their parents so they are visible
+func (m *Model) selectModifiedWithin(window time.Duration) int {
+	cutoff := time.Now().Add(-window)
+	count := 0
+
+	for i := range m.items {
+		if m.items[i].IsDir || m.isGitIgnored(m.items[i].Path) {
+			continue
+		}
+		info, err := os.Stat(m.items[i].Path)
+		if err != nil || info.ModTime().Before(cutoff) || m.skipsLargeFile(m.items[i]) {
+			continue
+		}
+
+		path := m.items[i].Path
+		m.toggleSelection(path, true)
+		m.ensureParentPathsExpanded(path)
+		count++
+	}
+
+	m.refreshVisibleItems()
+	m.setStatusMessage(fmt.Sprintf("Selected %d files modified in the last %s%s", count, window, m.skippedLargeNote()), 2)
+	return count
+}
+
+// contextDir is the repository directory holding shared context files
+const contextDir = ".llmdog"
+
+// loadContextPatterns reads the paths and globs listed in .llmdog/<name>.txt,
+// skipping blank lines and # comments
+func loadContextPatterns(cwd, name string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(cwd, contextDir, name+".txt"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read context %q: %w", name, err)
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// matchGlob reports whether the slash-separated path name matches pattern.
+// Besides the usual glob syntax, a ** segment matches any number of directories.
+func matchGlob(pattern, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchGlobSegments matches path segments against pattern segments
+func matchGlobSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// selectByGlobs selects the items whose paths relative to cwd match any of the
+// patterns, expanding their parents so they are visible. It returns the number
+// of items selected and the patterns that matched nothing.
+func (m *Model) selectByGlobs(patterns []string) (int, []string) {
+	matched := make(map[string]bool)
+	var paths []string
+	for _, item := range m.items {
+		if m.isGitIgnored(item.Path) {
+			continue
+		}
+		rel, err := filepath.Rel(m.cwd, item.Path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		hit := false
+		for _, pattern := range patterns {
+			if matchGlob(strings.TrimPrefix(strings.TrimSuffix(pattern, "/"), "./"), rel) {
+				matched[pattern] = true
+				hit = true
+			}
+		}
+		if hit {
+			paths = append(paths, item.Path)
+		}
+	}
+
+	// Selecting can load more items, so work from the collected paths
+	for _, path := range paths {
+		m.toggleSelection(path, true)
+		m.ensureParentPathsExpanded(path)
+	}
+	m.refreshVisibleItems()
+
+	var unmatched []string
+	for _, pattern := range patterns {
+		if !matched[pattern] {
+			unmatched = append(unmatched, pattern)
+		}
+	}
+	return len(paths), unmatched
+}
+
+// applyContext selects the files listed in the repository context file .llmdog/<name>.txt
+func (m *Model) applyContext(name string) {
+	patterns, err := loadContextPatterns(m.cwd, name)
+	if err != nil {
+		m.addError(err)
+		return
+	}
+
+	count, unmatched := m.selectByGlobs(patterns)
+	m.setStatusMessage(fmt.Sprintf("Applied context %s: selected %d items", name, count), 3)
+	if len(unmatched) > 0 {
+		m.addError(fmt.Errorf("Warning: context %s has entries matching nothing: %s", name, strings.Join(unmatched, ", ")))
+	}
+}
+
+// applySelectPatterns selects the files matching the --select globs
+func (m *Model) applySelectPatterns(patterns []string) {
+	count, unmatched := m.selectByGlobs(patterns)
+	m.setStatusMessage(fmt.Sprintf("Selected %d items matching --select", count), 3)
+	if len(unmatched) > 0 {
+		m.addError(fmt.Errorf("Warning: --select patterns matching nothing: %s", strings.Join(unmatched, ", ")))
+	}
+}
+
+// ParseAge parses a time window such as "90m", "6h", "2d" or "1w"
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if strings.HasSuffix(value, suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(value, suffix), 64)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid time window: %s", value)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid time window: %s", value)
+	}
+	return d, nil
+}
+
+// toggleContentSearchMode toggles content search mode
+func (m *Model) toggleContentSearchMode() {
+	if m.config.SafeMode {
+		m.setStatusMessage("Content search reads files, so it's off in safe mode", 3)
+		return
+	}
+	m.contentSearchMode = !m.contentSearchMode
+	m.config.ContentSearchMode = m.contentSearchMode
+
+	// Persist only this setting so project overrides never leak into the global config
+	if globalConfig, err := LoadConfig(); err == nil {
+		globalConfig.ContentSearchMode = m.contentSearchMode
+		saveConfig(globalConfig, globalConfigPath())
+	}
+
+	if m.contentSearchMode {
 		m.setStatusMessage("Content search enabled", 2)
 	} else {
 		m.setStatusMessage("Content search disabled", 2)
 	}
 }
 
-// Init initializes the bubbletea model
-func (m *Model) Init() tea.Cmd {
-	return tea.Batch(
-		m.spinner.Tick,
-	)
+// Init initializes the bubbletea model
+func (m *Model) Init() tea.Cmd {
+	return tea.Batch(
+		m.spinner.Tick,
+	)
+}
+
+// BuildOutput creates the markdown output from selected items
+func BuildOutput(items []ui.FileItem, cwd string, config Config) string {
+	return buildOutput(items, cwd, config, nil)
+}
+
+// buildOutput builds the output like BuildOutput, recording what it leaves out
+// in stats unless that is nil
+func buildOutput(items []ui.FileItem, cwd string, config Config, stats *outputStats) string {
+	// Selected directories only stand for their files, so their trees aren't repeated
+	if config.FilesOnly {
+		var files []ui.FileItem
+		for _, item := range items {
+			if !item.IsDir {
+				files = append(files, item)
+			}
+		}
+		items = files
+	}
+
+	if config.EntryPointsFirst {
+		items = entryPointsFirst(items, cwd, config.EntryPoints)
+	}
+	if config.IncludeReadme {
+		items = readmeFirst(items, cwd)
+	}
+
+	if config.OutputFormat == FormatCompact {
+		return buildCompactOutput(items, cwd, config, stats)
+	}
+	if config.OutputFormat == FormatJSONL {
+		var sb strings.Builder
+		writeJSONL(&sb, items, cwd, config, stats)
+		return sb.String()
+	}
+	if config.OutputFormat == FormatOpenAI {
+		config.OutputFormat = FormatMarkdown
+		return buildOpenAIOutput(buildOutput(items, cwd, config, stats), items, cwd, config)
+	}
+
+	var sb strings.Builder
+
+	// File structure section
+	if config.IncludeStructure {
+		sb.WriteString(structureSection(items, cwd, config))
+	}
+	// Without it, the next section shouldn't start with a blank line
+	writeSection := func(section string) {
+		if sb.Len() == 0 {
+			section = strings.TrimPrefix(section, "\n")
+		}
+		sb.WriteString(section)
+	}
+
+	// File contents section, built first so the table of contents knows the headers
+	var contents strings.Builder
+	var headers []string
+	fileCount, byteCount := 0, 0
+
+	// Headers can link to the files on the repository's web host
+	var link func(path string, start, end int) string
+	if config.LinkFiles {
+		link = fileLinker(cwd)
+	}
+	contents.WriteString("\n# File Contents\n")
+
+	// Files changed since HEAD can be shown as their diff instead
+	changed := make(map[string]bool)
+	if config.ChangedAsDiff {
+		if files, err := git.GetModifiedFiles(cwd); err == nil {
+			for _, file := range files {
+				changed[file] = true
+			}
+		}
+	}
+	// Grouped files follow their directory's header, so they are sorted by directory
+	contentItems := items
+	currentDir := ""
+	if config.GroupByDirectory {
+		contentItems = groupByDirectory(items, cwd)
+	}
+	for _, item := range contentItems {
+		if !item.IsDir {
+			rel, err := filepath.Rel(cwd, item.Path)
+			if err != nil {
+				rel = item.Path
+			}
+
+			if dir := filepath.ToSlash(filepath.Dir(rel)); config.GroupByDirectory && dir != currentDir {
+				contents.WriteString(fmt.Sprintf("\n# Directory: %s\n", dir))
+				currentDir = dir
+			}
+
+			// Notes go right above the file they are about
+			if item.Note != "" {
+				contents.WriteString(fmt.Sprintf("\n<!-- Note: %s -->", item.Note))
+			}
+
+			file, err := processedContent(item, config)
+			if err == nil && file.note != "" {
+				contents.WriteString(fmt.Sprintf("\n## File: %s\n", rel))
+				contents.WriteString(fmt.Sprintf("Skipped: %s\n", file.note))
+				headers = append(headers, rel)
+			} else if err == nil {
+				content := file.content
+				header := rel
+				language := languageFor(item.Path)
+				diff := ""
+				if changed[item.Path] && item.LineStart == 0 {
+					diff, _ = git.GetFileDiff(cwd, item.Path)
+				}
+				if diff != "" {
+					content, language = []byte(diff), "diff"
+					header = fmt.Sprintf("%s (diff against HEAD)", rel)
+				} else if item.LineStart > 0 {
+					header = fmt.Sprintf("%s (lines %d-%d of %d)", rel, item.LineStart, item.LineEnd, file.total)
+					if end := min(file.end, file.total); file.start != item.LineStart || end != min(item.LineEnd, file.total) {
+						header = fmt.Sprintf("%s (lines %d-%d of %d, showing %d-%d for context)",
+							rel, item.LineStart, item.LineEnd, file.total, file.start, end)
+					}
+				}
+				// One huge file, such as a minified bundle, shouldn't crowd out the rest
+				if note := tokenLimitNote(content, config); note != "" {
+					stats.skipOverTokenLimit(rel)
+					contents.WriteString(fmt.Sprintf("\n## File: %s\n", header))
+					contents.WriteString(fmt.Sprintf("Skipped: %s\n", note))
+					headers = append(headers, header)
+					continue
+				}
+				if diff == "" {
+					stats.recordDedent(rel, item.Path, file)
+				}
+				if config.IncludeBlame {
+					if author, date, err := git.GetLastCommit(cwd, item.Path); err == nil && author != "" {
+						header = fmt.Sprintf("%s (last changed by %s on %s)", header, author, date)
+					}
+				}
+
+				url := ""
+				if link != nil {
+					url = link(item.Path, item.LineStart, item.LineEnd)
+				}
+				if config.CollapsibleFiles {
+					// Collapsible block for markdown-rendering chat UIs
+					summary := header
+					if url != "" {
+						summary = fmt.Sprintf("<a href=\"%s\">%s</a>", url, header)
+					}
+					contents.WriteString(fmt.Sprintf("\n<details>\n<summary>%s</summary>\n\n", summary))
+				} else if url != "" {
+					contents.WriteString(fmt.Sprintf("\n## File: [%s](%s)\n", header, url))
+				} else {
+					contents.WriteString(fmt.Sprintf("\n## File: %s\n", header))
+				}
+				headers = append(headers, header)
+				contents.WriteString("```" + language + "\n")
+				contents.WriteString(string(content))
+				if !strings.HasSuffix(string(content), "\n") {
+					contents.WriteString("\n")
+				}
+				contents.WriteString("```\n")
+				fileCount++
+				byteCount += len(content)
+				if config.CollapsibleFiles {
+					contents.WriteString("\n</details>\n")
+				}
+			}
+		}
+	}
+
+	if config.ImportGraph {
+		writeSection(importGraphSection(items, cwd))
+	}
+
+	if config.TableOfContents && len(headers) > 0 {
+		writeSection("\n# Table of Contents\n")
+		for _, header := range headers {
+			if config.CollapsibleFiles {
+				// Details blocks have no headers to link to
+				sb.WriteString(fmt.Sprintf("- %s\n", header))
+			} else {
+				sb.WriteString(fmt.Sprintf("- [%s](#%s)\n", header, markdownAnchor("File: "+header)))
+			}
+		}
+	}
+	writeSection(contents.String())
+
+	// Command output section, only when explicitly enabled since it executes a command
+	if config.RunContextCommand && config.ContextCommand != "" {
+		sb.WriteString("\n# Command Output\n")
+		sb.WriteString("```\n")
+		sb.WriteString(runContextCommand(config.ContextCommand, cwd))
+		sb.WriteString("```\n")
+	}
+
+	if config.IncludeMetadata {
+		sb.WriteString(metadataTrailer(sb.String(), fileCount, byteCount))
+	}
+	return sb.String()
+}
+
+// structureSection renders the directory structure section of the markdown output
+func structureSection(items []ui.FileItem, cwd string, config Config) string {
+	var sb strings.Builder
+	sb.WriteString("# Directory Structure\n```\n")
+	if config.FlatStructure {
+		sb.WriteString(buildFlatStructure(items, cwd, config))
+	} else if config.ShowParentDirs {
+		sb.WriteString(buildParentTree(items, cwd, config))
+	} else {
+		sb.WriteString(buildNestedStructure(items, cwd, config))
+	}
+	sb.WriteString("```\n")
+	return sb.String()
+}
+
+// fileLinker returns a function building web links to files at the current
+// commit, or nil when cwd isn't in a repository with a remote on a web host
+func fileLinker(cwd string) func(path string, start, end int) string {
+	webURL := git.WebURL(git.GetRemote(cwd))
+	commit := git.GetCommit(cwd)
+	root := git.GetTopLevel(cwd)
+	if webURL == "" || commit == "" || root == "" {
+		return nil
+	}
+
+	return func(path string, start, end int) string {
+		// git reports the root with symlinks resolved
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return ""
+		}
+		return git.FileURL(webURL, commit, rel, start, end)
+	}
+}
+
+// metadataTrailer summarizes the output above it, with a checksum, so a saved
+// output can be checked later
+func metadataTrailer(output string, files, bytes int) string {
+	var sb strings.Builder
+	sb.WriteString("\n# Metadata\n")
+	sb.WriteString(fmt.Sprintf("- Files: %d\n", files))
+	sb.WriteString(fmt.Sprintf("- Bytes: %d\n", bytes))
+	sb.WriteString(fmt.Sprintf("- Estimated tokens: ~%d\n", tokens.Estimate(output)))
+	sb.WriteString(fmt.Sprintf("- SHA-256 of the output above: %x\n", sha256.Sum256([]byte(output))))
+	sb.WriteString(fmt.Sprintf("- Generated: %s\n", time.Now().Format(time.RFC3339)))
+	return sb.String()
+}
+
+// markdownAnchor returns the anchor markdown renderers such as GitHub generate for a header
+func markdownAnchor(header string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(header) {
+		switch {
+		case r == ' ' || r == '-':
+			sb.WriteRune('-')
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// shellCommand runs command through the platform's shell
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// RunPostCopyCommand runs the configured post-copy command after the selection
+// was confirmed with Enter. The output is passed on stdin; a {file} placeholder
+// in the command is replaced by the path of a temporary file holding it.
+func (m *Model) RunPostCopyCommand() error {
+	if m.config.PostCopyCommand == "" || !m.confirmed || m.lastOutput == "" {
+		return nil
+	}
+
+	command := m.config.PostCopyCommand
+	if strings.Contains(command, "{file}") {
+		file, err := os.CreateTemp("", "llmdog-*.md")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(m.lastOutput)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		command = strings.ReplaceAll(command, "{file}", file.Name())
+	}
+
+	cmd := shellCommand(command)
+	cmd.Dir = m.cwd
+	cmd.Stdin = strings.NewReader(m.lastOutput)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	slog.Info("running post-copy command", "command", command)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("post-copy command failed: %w", err)
+	}
+	return nil
+}
+
+// runContextCommand runs command in dir through the shell and returns its
+// combined stdout/stderr, prefixed by the command line and followed by the
+// exit status if it failed
+func runContextCommand(command, dir string) string {
+	cmd := shellCommand(command)
+	cmd.Dir = dir
+
+	slog.Info("running context command", "command", command, "dir", dir)
+	output, err := cmd.CombinedOutput()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("$ %s\n", command))
+	sb.Write(output)
+	if len(output) > 0 && !bytes.HasSuffix(output, []byte("\n")) {
+		sb.WriteString("\n")
+	}
+	if err != nil {
+		slog.Warn("context command failed", "command", command, "err", err)
+		sb.WriteString(fmt.Sprintf("[%v]\n", err))
+	}
+	return sb.String()
+}
+
+func buildTree(root string, prefix string, config Config) string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return fmt.Sprintf("Error reading directory: %v", err)
+	}
+
+	// Collect the entries to render first so the last one is known for the connectors
+	var visible []os.DirEntry
+	for _, entry := range entries {
+		inGit, shown := ui.GitMetadataPath(filepath.Join(root, entry.Name()), config.GitMetadata)
+		if inGit && !shown {
+			continue
+		}
+		if !inGit && entry.IsDir() && ui.IsExcludedDir(entry.Name(), config.ExcludeDirs) {
+			continue
+		}
+		if !inGit && !entry.IsDir() && !ui.IsIncludedFile(entry.Name(), config.IncludeExtensions) {
+			continue
+		}
+		if entry.IsDir() && isEmptyDir(filepath.Join(root, entry.Name())) && !config.IncludeEmptyDirs {
+			// Empty directories are only meaningful when explicitly requested
+			continue
+		}
+		visible = append(visible, entry)
+	}
+
+	var sb strings.Builder
+	for idx, entry := range visible {
+		path := filepath.Join(root, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		// Pick the connector and the prefix for this entry's children
+		connector, childPrefix := "|- ", prefix+config.TreeIndent
+		if config.TreeStyle == TreeStyleUnicode {
+			if idx == len(visible)-1 {
+				connector, childPrefix = "└── ", prefix+"    "
+			} else {
+				connector, childPrefix = "├── ", prefix+"│   "
+			}
+		}
+
+		if info.IsDir() {
+			if isEmptyDir(path) {
+				sb.WriteString(fmt.Sprintf("%s%s%s/ (empty)\n", prefix, connector, entry.Name()))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("%s%s%s/\n", prefix, connector, entry.Name()))
+			sb.WriteString(buildTree(path, childPrefix, config))
+		} else {
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", prefix, connector, entry.Name()))
+		}
+	}
+	return sb.String()
+}
+
+// buildNestedStructure lists each selected item, expanding selected directories into an indented tree
+func buildNestedStructure(items []ui.FileItem, cwd string, config Config) string {
+	var sb strings.Builder
+	for _, item := range items {
+		rel, err := filepath.Rel(cwd, item.Path)
+		if err != nil {
+			rel = item.Path
+		}
+		if item.IsDir {
+			if isEmptyDir(item.Path) && !config.IncludeEmptyDirs {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("%s/\n", rel))
+			sb.WriteString(buildTree(item.Path, "", config))
+		} else {
+			sb.WriteString(fmt.Sprintf("%s\n", rel))
+		}
+	}
+	return sb.String()
+}
+
+// parentTreeNode is a directory or file in the tree built by buildParentTree
+type parentTreeNode struct {
+	name     string
+	path     string
+	isDir    bool
+	whole    bool // Selected directory, rendered with its full contents
+	children map[string]*parentTreeNode
+}
+
+// buildParentTree renders the selected files nested under their ancestor
+// directories, without the ancestors' other children. Selected directories
+// are rendered with their full contents.
+func buildParentTree(items []ui.FileItem, cwd string, config Config) string {
+	root := &parentTreeNode{path: cwd, isDir: true, children: make(map[string]*parentTreeNode)}
+
+	for _, item := range items {
+		rel, err := filepath.Rel(cwd, item.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		node := root
+		parts := strings.Split(rel, string(os.PathSeparator))
+		for i, part := range parts {
+			if node.whole {
+				break // Already covered by a selected ancestor
+			}
+			child, ok := node.children[part]
+			if !ok {
+				child = &parentTreeNode{
+					name:     part,
+					path:     filepath.Join(node.path, part),
+					isDir:    i < len(parts)-1 || item.IsDir,
+					children: make(map[string]*parentTreeNode),
+				}
+				node.children[part] = child
+			}
+			node = child
+		}
+		if item.IsDir && !node.whole {
+			node.whole = true
+			node.children = nil
+		}
+	}
+
+	var sb strings.Builder
+	writeParentTree(&sb, root, "", config)
+	return sb.String()
+}
+
+// writeParentTree writes the children of node using the configured tree style
+func writeParentTree(sb *strings.Builder, node *parentTreeNode, prefix string, config Config) {
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for idx, name := range names {
+		child := node.children[name]
+
+		connector, childPrefix := "|- ", prefix+config.TreeIndent
+		if config.TreeStyle == TreeStyleUnicode {
+			if idx == len(names)-1 {
+				connector, childPrefix = "└── ", prefix+"    "
+			} else {
+				connector, childPrefix = "├── ", prefix+"│   "
+			}
+		}
+
+		switch {
+		case child.whole:
+			sb.WriteString(fmt.Sprintf("%s%s%s/\n", prefix, connector, child.name))
+			sb.WriteString(buildTree(child.path, childPrefix, config))
+		case child.isDir:
+			sb.WriteString(fmt.Sprintf("%s%s%s/\n", prefix, connector, child.name))
+			writeParentTree(sb, child, childPrefix, config)
+		default:
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", prefix, connector, child.name))
+		}
+	}
 }
 
-// BuildOutput creates the markdown output from selected items
-func BuildOutput(items []ui.FileItem, cwd string) string {
+// buildFlatStructure lists the relative path of every selected file, including
+// the files inside selected directories, one per line without nesting
+func buildFlatStructure(items []ui.FileItem, cwd string, config Config) string {
 	var sb strings.Builder
+	seen := make(map[string]bool)
 
-	// File structure section
-	sb.WriteString("# Directory Structure\n```\n")
+	addPath := func(path string, isDir bool) {
+		rel, err := filepath.Rel(cwd, path)
+		if err != nil {
+			rel = path
+		}
+		if isDir {
+			rel += "/"
+		}
+		if !seen[rel] {
+			seen[rel] = true
+			sb.WriteString(rel + "\n")
+		}
+	}
+
+	for _, item := range items {
+		if !item.IsDir {
+			addPath(item.Path, false)
+			continue
+		}
+
+		filepath.WalkDir(item.Path, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			inGit, shown := ui.GitMetadataPath(path, config.GitMetadata)
+			if inGit && !shown {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !inGit && entry.IsDir() && path != item.Path && ui.IsExcludedDir(entry.Name(), config.ExcludeDirs) {
+				return filepath.SkipDir
+			}
+			if !entry.IsDir() {
+				if inGit || ui.IsIncludedFile(entry.Name(), config.IncludeExtensions) {
+					addPath(path, false)
+				}
+			} else if config.IncludeEmptyDirs && isEmptyDir(path) {
+				addPath(path, true)
+			}
+			return nil
+		})
+	}
+	return sb.String()
+}
+
+// openAIMessage is a chat message in the OpenAI chat completions shape
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIOutput is the body written by the openai format
+type openAIOutput struct {
+	Messages []openAIMessage `json:"messages"`
+	Metadata struct {
+		EstimatedTokens int `json:"estimated_tokens"`
+		Files           int `json:"files"`
+	} `json:"metadata"`
+}
+
+// buildOpenAIOutput wraps the markdown output in a system message with the
+// preamble and a user message with the files
+func buildOpenAIOutput(content string, items []ui.FileItem, cwd string, config Config) string {
+	preamble := renderPreamble(loadPreamble(config, cwd), content, items, cwd)
+
+	var output openAIOutput
+	output.Messages = []openAIMessage{
+		{Role: "system", Content: preamble},
+		{Role: "user", Content: content},
+	}
+	output.Metadata.EstimatedTokens = tokens.Estimate(preamble) + tokens.Estimate(content)
+	for _, item := range items {
+		if !item.IsDir {
+			output.Metadata.Files++
+		}
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(data) + "\n"
+}
+
+// jsonlRecord is a single file in the JSON Lines output
+type jsonlRecord struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+	Skipped  string `json:"skipped,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// WriteJSONL writes one JSON object per selected file to w, reading each file
+// only when its record is written so large selections are never buffered
+func WriteJSONL(w io.Writer, items []ui.FileItem, cwd string, config Config) error {
+	return writeJSONL(w, items, cwd, config, nil)
+}
+
+// writeJSONL writes the records like WriteJSONL, recording what it leaves out
+// in stats unless that is nil
+func writeJSONL(w io.Writer, items []ui.FileItem, cwd string, config Config, stats *outputStats) error {
+	encoder := json.NewEncoder(w)
 	for _, item := range items {
+		if item.IsDir {
+			continue
+		}
+
 		rel, err := filepath.Rel(cwd, item.Path)
 		if err != nil {
 			rel = item.Path
 		}
+
+		file, err := processedContent(item, config)
+		if err != nil {
+			slog.Warn("could not read file", "path", item.Path, "err", err)
+			continue
+		}
+
+		record := jsonlRecord{Path: filepath.ToSlash(rel), Language: languageFor(item.Path), Skipped: file.note, Note: item.Note}
+		if file.note == "" {
+			if record.Skipped = tokenLimitNote(file.content, config); record.Skipped == "" {
+				record.Content = string(file.content)
+				stats.recordDedent(rel, item.Path, file)
+			} else {
+				stats.skipOverTokenLimit(rel)
+			}
+		}
+
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSelection writes the output for the current selection to w without the
+// TUI. When nothing is selected, every item that isn't gitignored is written.
+func (m *Model) WriteSelection(w io.Writer) error {
+	var items []ui.FileItem
+	for _, item := range m.items {
+		if item.Selected && !m.isGitIgnored(item.Path) {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		for _, item := range m.items {
+			if !m.isGitIgnored(item.Path) {
+				items = append(items, item)
+			}
+		}
+	}
+
+	items = m.withReadme(items)
+	items, m.droppedFiles = fitTokenBudget(items, m.cwd, m.maxTokens)
+	m.lastItems = items
+	m.lastStats = outputStats{}
+
+	if m.config.OutputFormat == FormatJSONL {
+		return writeJSONL(w, items, m.cwd, m.config, &m.lastStats)
+	}
+	_, err := io.WriteString(w, buildOutput(items, m.cwd, m.config, &m.lastStats))
+	return err
+}
+
+// WriteTree writes the file tree to w without any file contents. It holds the
+// same entries as the TUI, without gitignored ones; with maxDepth set, only
+// entries up to that many levels deep are included.
+func (m *Model) WriteTree(w io.Writer, maxDepth int) error {
+	children := make(map[string][]ui.FileItem)
+	for _, item := range m.items {
+		if m.isGitIgnored(item.Path) || (maxDepth > 0 && item.Depth >= maxDepth) {
+			continue
+		}
+		parent := filepath.Dir(item.Path)
+		children[parent] = append(children[parent], item)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(filepath.Base(m.cwd) + "/\n")
+	writeItemTree(&sb, children, m.cwd, "", m.config)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeItemTree renders the items below dir, in the order they were loaded
+func writeItemTree(sb *strings.Builder, children map[string][]ui.FileItem, dir, prefix string, config Config) {
+	items := children[dir]
+	for idx, item := range items {
+		connector, childPrefix := "|- ", prefix+config.TreeIndent
+		if config.TreeStyle == TreeStyleUnicode {
+			if idx == len(items)-1 {
+				connector, childPrefix = "└── ", prefix+"    "
+			} else {
+				connector, childPrefix = "├── ", prefix+"│   "
+			}
+		}
+
 		if item.IsDir {
-			sb.WriteString(fmt.Sprintf("%s/\n", rel))
-			sb.WriteString(buildTree(item.Path, 0))
+			sb.WriteString(fmt.Sprintf("%s%s%s/\n", prefix, connector, item.Name))
+			writeItemTree(sb, children, item.Path, childPrefix, config)
 		} else {
-			sb.WriteString(fmt.Sprintf("%s\n", rel))
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", prefix, connector, item.Name))
 		}
 	}
-	sb.WriteString("```\n")
+}
+
+// buildCompactOutput lists each selected file on a single line without its content
+func buildCompactOutput(items []ui.FileItem, cwd string, config Config, stats *outputStats) string {
+	var sb strings.Builder
 
-	// File contents section
-	sb.WriteString("\n# File Contents\n")
+	sb.WriteString("# Selected Files\n")
 	for _, item := range items {
-		if !item.IsDir {
-			rel, err := filepath.Rel(cwd, item.Path)
-			if err != nil {
-				rel = item.Path
+		if item.IsDir {
+			continue
+		}
+
+		rel, err := filepath.Rel(cwd, item.Path)
+		if err != nil {
+			rel = item.Path
+		}
+
+		file, err := processedContent(item, config)
+		if err != nil {
+			continue
+		}
+		if file.note != "" {
+			sb.WriteString(fmt.Sprintf("%s (%s)\n", rel, file.note))
+			continue
+		}
+		content := file.content
+		if note := tokenLimitNote(content, config); note != "" {
+			stats.skipOverTokenLimit(rel)
+			sb.WriteString(fmt.Sprintf("%s (%s)\n", rel, note))
+			continue
+		}
+		stats.recordDedent(rel, item.Path, file)
+
+		sb.WriteString(fmt.Sprintf("%s (%s, %d lines, ~%d tokens)\n",
+			rel, languageFor(item.Path), countLines(string(content)), tokens.Estimate(string(content))))
+	}
+	return sb.String()
+}
+
+// tokenLimitNote explains why content is left out of the output when its
+// estimated token count exceeds config.MaxFileTokens, or returns "" if it fits
+func tokenLimitNote(content []byte, config Config) string {
+	if config.MaxFileTokens <= 0 {
+		return ""
+	}
+	if count := tokens.Estimate(string(content)); count > config.MaxFileTokens {
+		return fmt.Sprintf("~%d tokens, over the maxFileTokens limit of %d", count, config.MaxFileTokens)
+	}
+	return ""
+}
+
+// outputStats records what building an output left out, so it can be reported
+// without reading the files again
+type outputStats struct {
+	overTokenLimit  []string // Relative paths of the files over config.MaxFileTokens
+	dedentSaved     int      // Estimated tokens dedent removed
+	dedentSensitive []string // Whitespace-sensitive files whose indentation dedent changed
+}
+
+// skipOverTokenLimit records that the file at rel was left out for exceeding
+// config.MaxFileTokens
+func (s *outputStats) skipOverTokenLimit(rel string) {
+	if s != nil {
+		s.overTokenLimit = append(s.overTokenLimit, rel)
+	}
+}
+
+// recordDedent adds what dedenting the file at rel saved, warning about it if
+// the language at path depends on indentation
+func (s *outputStats) recordDedent(rel, path string, file processedFile) {
+	if s == nil || !file.dedented {
+		return
+	}
+	s.dedentSaved += file.dedentSaved
+	if whitespaceSensitive[strings.ToLower(languageFor(path))] {
+		s.dedentSensitive = append(s.dedentSensitive, rel)
+	}
+}
+
+// processedFile is a file's content as it goes into the output
+type processedFile struct {
+	content     []byte
+	note        string // Why the file is left out, such as being binary
+	start, end  int    // The lines shown, when the item has a line range
+	total       int    // The file's line count, when the item has a line range
+	dedentSaved int    // Estimated tokens dedent removed
+	dedented    bool   // Whether dedent changed the content
+}
+
+// processedContent reads the item and applies the transformations, line range
+// and token-saving clean-ups in the order every output format uses
+func processedContent(item ui.FileItem, config Config) (processedFile, error) {
+	content, note, err := readTextFile(item.Path)
+	if err != nil || note != "" {
+		return processedFile{note: note}, err
+	}
+
+	var file processedFile
+	content = transformContent(content, config)
+	if item.LineStart > 0 {
+		file.start, file.end = contextRange(item, config)
+		content, file.total = applyLineRange(content, file.start, file.end)
+	}
+	content = trimContent(content, item.Path, config)
+	if config.Dedent {
+		dedented := dedent(content)
+		if file.dedented = len(dedented) != len(content); file.dedented {
+			file.dedentSaved = tokens.Estimate(string(content)) - tokens.Estimate(string(dedented))
+		}
+		content = dedented
+	}
+	file.content = content
+	return file, nil
+}
+
+// readRegularFile reads a file's content. Anything that isn't a regular file,
+// such as a fifo or device, is not read since that can block forever; a note
+// describing the file is returned instead.
+func readRegularFile(path string) ([]byte, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		// A dangling symlink can't be followed but is still worth mentioning
+		if target, linkErr := os.Readlink(path); linkErr == nil {
+			return nil, fmt.Sprintf("symlink -> %s (broken)", target), nil
+		}
+		return nil, "", err
+	}
+
+	if !info.Mode().IsRegular() {
+		if target, err := os.Readlink(path); err == nil {
+			return nil, fmt.Sprintf("symlink -> %s", target), nil
+		}
+		return nil, fmt.Sprintf("not a regular file (%s)", describeFileMode(info.Mode())), nil
+	}
+
+	content, err := os.ReadFile(path)
+	return content, "", err
+}
+
+// readTextFile reads a regular file as UTF-8 text, transcoding other encodings
+// where possible. Content that can't be decoded is skipped with a note rather
+// than being pasted as garbage.
+func readTextFile(path string) ([]byte, string, error) {
+	content, note, err := readRegularFile(path)
+	if err != nil || note != "" {
+		return content, note, err
+	}
+
+	decoded, _, ok := ui.DecodeText(content)
+	if !ok {
+		return nil, "not valid UTF-8 text (binary or unsupported encoding)", nil
+	}
+	return decoded, "", nil
+}
+
+// describeFileMode returns a readable name for a non-regular file type
+func describeFileMode(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	default:
+		return mode.Type().String()
+	}
+}
+
+// transformContent applies the configured clean-ups to a file's content before output
+func transformContent(content []byte, config Config) []byte {
+	if config.NormalizeLineEndings {
+		content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+		content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	}
+	return content
+}
+
+// isEntryPoint reports whether the file at rel matches one of the entry point
+// patterns. Patterns without a slash match the file name in any directory.
+func isEntryPoint(rel string, patterns []string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+				return true
 			}
+			continue
+		}
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
 
-			content, err := os.ReadFile(item.Path)
-			if err == nil {
-				ext := filepath.Ext(item.Path)
-				if ext == "" {
-					ext = "txt"
-				} else {
-					ext = ext[1:]
-				}
+// entryPointsFirst moves entry point files to the front, keeping the order of
+// everything else
+func entryPointsFirst(items []ui.FileItem, cwd string, patterns []string) []ui.FileItem {
+	var entries, rest []ui.FileItem
+	for _, item := range items {
+		rel, err := filepath.Rel(cwd, item.Path)
+		if !item.IsDir && err == nil && isEntryPoint(rel, patterns) {
+			entries = append(entries, item)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	return append(entries, rest...)
+}
+
+// readmeExtensions are the extensions a README file is recognized with
+var readmeExtensions = map[string]bool{"": true, ".md": true, ".markdown": true, ".txt": true, ".rst": true, ".adoc": true, ".org": true}
+
+// isReadme reports whether name is a common README file name, in any case
+func isReadme(name string) bool {
+	ext := filepath.Ext(name)
+	return strings.EqualFold(strings.TrimSuffix(name, ext), "readme") && readmeExtensions[strings.ToLower(ext)]
+}
+
+// readmeFirst moves the README in cwd to the front, keeping the order of
+// everything else
+func readmeFirst(items []ui.FileItem, cwd string) []ui.FileItem {
+	for i, item := range items {
+		if !item.IsDir && filepath.Dir(item.Path) == cwd && isReadme(item.Name) {
+			rest := append(append([]ui.FileItem(nil), items[:i]...), items[i+1:]...)
+			return append([]ui.FileItem{item}, rest...)
+		}
+	}
+	return items
+}
+
+// withReadme adds the project's top-level README to the front of items when
+// the includeReadme config key is set, whether or not it is selected
+func (m *Model) withReadme(items []ui.FileItem) []ui.FileItem {
+	if !m.config.IncludeReadme {
+		return items
+	}
+	for _, item := range items {
+		if !item.IsDir && filepath.Dir(item.Path) == m.cwd && isReadme(item.Name) {
+			return readmeFirst(items, m.cwd)
+		}
+	}
+	for _, item := range m.items {
+		if !item.IsDir && filepath.Dir(item.Path) == m.cwd && isReadme(item.Name) && !m.isGitIgnored(item.Path) {
+			return append([]ui.FileItem{item}, items...)
+		}
+	}
+	return items
+}
+
+// groupByDirectory returns the files among items sorted by their directory
+// relative to cwd, then by name
+func groupByDirectory(items []ui.FileItem, cwd string) []ui.FileItem {
+	type groupedFile struct {
+		dir, name string
+		item      ui.FileItem
+	}
+	var files []groupedFile
+	for _, item := range items {
+		if item.IsDir {
+			continue
+		}
+		rel, err := filepath.Rel(cwd, item.Path)
+		if err != nil {
+			rel = item.Path
+		}
+		rel = filepath.ToSlash(rel)
+		files = append(files, groupedFile{path.Dir(rel), path.Base(rel), item})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].dir != files[j].dir {
+			return files[i].dir < files[j].dir
+		}
+		return files[i].name < files[j].name
+	})
+
+	grouped := make([]ui.FileItem, len(files))
+	for i, file := range files {
+		grouped[i] = file.item
+	}
+	return grouped
+}
+
+// trimContent applies the configured token-saving clean-ups. They run after
+// the line range is applied, since they can remove lines.
+func trimContent(content []byte, path string, config Config) []byte {
+	if config.SignaturesOnly {
+		if skeleton, ok := signaturesOnly(content, path); ok {
+			content = skeleton
+		}
+	}
+	if config.StripComments {
+		content = stripComments(content, languageFor(path))
+	}
+	return content
+}
+
+// dedent removes the leading whitespace shared by all non-blank lines,
+// keeping the relative indentation
+func dedent(content []byte) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+
+	common := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			common, first = indent, false
+			continue
+		}
+		// Keep the shared prefix, so mixed tabs and spaces are never mangled
+		n := 0
+		for n < len(common) && n < len(indent) && common[n] == indent[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if common == "" {
+		return content
+	}
+
+	var sb strings.Builder
+	for _, line := range lines {
+		if strings.HasPrefix(line, common) {
+			line = line[len(common):]
+		} else if strings.TrimSpace(line) == "" {
+			line = strings.TrimLeft(line, " \t")
+		}
+		sb.WriteString(line)
+	}
+	return []byte(sb.String())
+}
+
+// whitespaceSensitive lists the languages where indentation changes meaning
+var whitespaceSensitive = map[string]bool{"py": true, "yaml": true, "yml": true}
+
+// copyNotes describes what the token-saving options changed in the last copy
+func (m *Model) copyNotes() string {
+	note := ""
+	if over := m.OverTokenLimit(); len(over) > 0 {
+		note += fmt.Sprintf(", skipped %d over maxFileTokens: %s", len(over), strings.Join(over, ", "))
+	}
+	if !m.config.Dedent {
+		return note
+	}
+	note += fmt.Sprintf(", dedent saved ~%d tokens", m.lastStats.dedentSaved)
+	if sensitive := m.lastStats.dedentSensitive; len(sensitive) > 0 {
+		note += fmt.Sprintf(" (warning: changed indentation of %s)", strings.Join(sensitive, ", "))
+	}
+	return note
+}
 
-				sb.WriteString(fmt.Sprintf("\n## File: %s\n", rel))
-				sb.WriteString("```" + ext + "\n")
-				sb.WriteString(string(content))
-				if !strings.HasSuffix(string(content), "\n") {
-					sb.WriteString("\n")
-				}
-				sb.WriteString("```\n")
-			}
-		}
+// applyLineRange returns only lines start through end (1-based, inclusive) of
+// content, along with the total number of lines in the file
+func applyLineRange(content []byte, start, end int) ([]byte, int) {
+	lines := strings.SplitAfter(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
 	}
-	return sb.String()
+
+	total := len(lines)
+	if start > total {
+		return nil, total
+	}
+	if end > total {
+		end = total
+	}
+	return []byte(strings.Join(lines[start-1:end], "")), total
 }
 
-func buildTree(root string, level int) string {
-	entries, err := os.ReadDir(root)
+// contextRange returns the lines to include for item's line range, widened by
+// the configured number of context lines on each side
+func contextRange(item ui.FileItem, config Config) (int, int) {
+	context := max(0, config.RangeContext)
+	return max(1, item.LineStart-context), item.LineEnd + context
+}
+
+// parseLineRange parses a range such as "100-200", "42" or "file.go:100-200".
+// The returned path is empty when the input doesn't name a file.
+func parseLineRange(input string) (string, int, int, error) {
+	input = strings.TrimSpace(input)
+	path := ""
+	if idx := strings.LastIndex(input, ":"); idx >= 0 {
+		path, input = input[:idx], input[idx+1:]
+	}
+
+	startText, endText, found := strings.Cut(input, "-")
+	if !found {
+		endText = startText
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(startText))
 	if err != nil {
-		return fmt.Sprintf("Error reading directory: %v", err)
+		return "", 0, 0, fmt.Errorf("invalid line range: %s", input)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(endText))
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid line range: %s", input)
+	}
+	if start < 1 || end < start {
+		return "", 0, 0, fmt.Errorf("invalid line range: %d-%d", start, end)
 	}
 
-	var sb strings.Builder
-	indent := strings.Repeat(" ", level*2)
+	return path, start, end, nil
+}
 
-	for _, entry := range entries {
-		path := filepath.Join(root, entry.Name())
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
+// languageFor returns the code fence language for a file based on its extension
+func languageFor(path string) string {
+	ext := filepath.Ext(path)
+	if ext == "" {
+		return "txt"
+	}
+	return ext[1:]
+}
 
-		if info.IsDir() {
-			sb.WriteString(fmt.Sprintf("%s|- %s/\n", indent, entry.Name()))
-			sb.WriteString(buildTree(path, level+1))
-		} else {
-			sb.WriteString(fmt.Sprintf("%s|- %s\n", indent, entry.Name()))
-		}
+// countLines counts the lines in content, including a final unterminated line
+func countLines(content string) int {
+	if content == "" {
+		return 0
 	}
-	return sb.String()
+	lines := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+	return lines
+}
+
+// isEmptyDir reports whether a directory has no entries at all
+func isEmptyDir(path string) bool {
+	entries, err := os.ReadDir(path)
+	return err == nil && len(entries) == 0
 }
 
 // Update updates the application state
@@ -767,6 +3035,36 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.setStatusMessage(msg.message, 2)
 		return m, nil
 
+	case contentSearchTickMsg:
+		// Only the last keystroke's tick starts a search
+		if msg.seq != m.contentSearchSeq || m.list.FilterState() != list.Filtering {
+			return m, nil
+		}
+		return m, m.startLiveContentSearch(msg.seq, m.list.FilterValue())
+
+	case contentSearchResultMsg:
+		if msg.seq != m.contentSearchSeq || m.list.FilterState() != list.Filtering {
+			return m, nil
+		}
+		m.contentScan = nil
+		return m, m.applyLiveContentSearch(msg.matches)
+
+	case contentSearchDoneMsg:
+		if msg.seq != m.contentSearchSeq {
+			return m, nil
+		}
+		m.contentScan = nil
+		m.performSearch(msg.query, msg.matches)
+		m.finishSearch(msg.submit)
+		return m, nil
+
+	case contentSearchProgressMsg:
+		// Keep redrawing the progress bar until the search is done
+		if msg.seq != m.contentSearchSeq || m.contentScan == nil {
+			return m, nil
+		}
+		return m, contentSearchProgressTick(msg.seq)
+
 	case childrenLoadedMsg:
 		// First mark the parent directory as having loaded children
 		for i := range m.items {
@@ -807,17 +3105,74 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.showTextInputModal = false
 				return m, nil
 
+			case "tab":
+				if m.textInputPurpose == "goto_path" {
+					m.completeGoToPath()
+					return m, nil
+				}
+				if m.textInputPurpose == "by_extension" {
+					m.showExtensionDialog(!m.extensionDeselect)
+					return m, nil
+				}
+				modal, cmd := m.textInputModal.Update(msg)
+				m.textInputModal = modal
+				return m, cmd
+
 			case "enter":
 				// Process based on purpose
-				inputValue := m.textInputModal.Value()
-				if inputValue == "" {
+				inputValue := strings.TrimSpace(m.textInputModal.Value())
+				if inputValue == "" && (m.textInputPurpose == "new_bookmark" || m.textInputPurpose == "rename_bookmark") {
 					m.setStatusMessage("Bookmark name cannot be empty", 2)
 					m.showTextInputModal = false
 					return m, nil
 				}
 
 				switch m.textInputPurpose {
+				case "modified_within":
+					if inputValue == "" {
+						inputValue = "24h"
+					}
+					window, err := ParseAge(inputValue)
+					if err != nil {
+						m.addError(err)
+					} else {
+						m.selectModifiedWithin(window)
+					}
+
+				case "file_note":
+					m.setNote(m.tempNotePath, inputValue)
+
+				case "line_range":
+					err := m.setLineRange(m.tempRangePath, inputValue)
+					if err != nil {
+						m.addError(err)
+					}
+
+				case "by_extension":
+					if inputValue == "" {
+						break
+					}
+					count := m.selectByExtension(inputValue, !m.extensionDeselect)
+					if m.extensionDeselect {
+						m.setStatusMessage(fmt.Sprintf("Deselected %d %s files", count, inputValue), 2)
+					} else {
+						m.setStatusMessage(fmt.Sprintf("Selected %d %s files%s", count, inputValue, m.skippedLargeNote()), 2)
+					}
+
+				case "goto_path":
+					if inputValue != "" {
+						if err := m.goToPath(inputValue); err != nil {
+							m.addError(err)
+						}
+					}
+
 				case "new_bookmark":
+					// Ask before clobbering an existing bookmark with the same name
+					if _, exists := m.bookmarkStore.GetBookmark(inputValue); exists {
+						m.showOverwriteBookmarkDialog(inputValue)
+						return m, nil
+					}
+
 					err := m.saveCurrentSelectionAsBookmark(inputValue, "")
 					if err != nil {
 						m.addError(err)
@@ -825,6 +3180,52 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.setStatusMessage(fmt.Sprintf("Saved bookmark: %s", inputValue), 2)
 					}
 
+				case "select_all":
+					answer := strings.ToLower(inputValue)
+					if answer == "" || answer == "y" || answer == "yes" {
+						m.selectAll()
+						m.setStatusMessage(fmt.Sprintf("Selected %d files%s", m.selectedCount, m.skippedLargeNote()), 2)
+					} else {
+						m.setStatusMessage("Selection unchanged", 2)
+					}
+
+				case "output_exists":
+					answer := strings.ToLower(inputValue)
+					if answer == "o" || answer == "overwrite" || answer == "a" || answer == "append" {
+						m.showTextInputModal = false
+						if err := m.writeOutputFile(strings.HasPrefix(answer, "a")); err != nil {
+							m.addError(err)
+							return m, nil
+						}
+						return m, m.finishConfirm()
+					}
+					m.setStatusMessage(fmt.Sprintf("Did not write %s (the output is still on the clipboard)", m.outputFile), 3)
+
+				case "overwrite_bookmark":
+					answer := strings.ToLower(strings.TrimSpace(inputValue))
+					if answer == "y" || answer == "yes" {
+						err := m.saveCurrentSelectionAsBookmark(m.tempBookmarkName, "")
+						if err != nil {
+							m.addError(err)
+						} else {
+							m.setStatusMessage(fmt.Sprintf("Overwrote bookmark: %s", m.tempBookmarkName), 2)
+						}
+					} else {
+						m.setStatusMessage("Bookmark not saved", 2)
+					}
+
+				case "merge_bookmarks":
+					if inputValue == "" {
+						m.setStatusMessage("Bookmark name cannot be empty", 2)
+						break
+					}
+					count, err := m.mergeBookmarks(m.tempMergeNames, inputValue)
+					if err != nil {
+						m.addError(err)
+					} else {
+						m.setStatusMessage(fmt.Sprintf("Merged %d bookmarks into %s (%d files)", len(m.tempMergeNames), inputValue, count), 2)
+					}
+
 				case "rename_bookmark":
 					err := m.renameBookmark(m.tempBookmarkName, inputValue)
 					if err != nil {
@@ -869,6 +3270,22 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// Handle the line range picker if active
+		if m.rangePicker != nil {
+			m.updateRangePicker(msg.String())
+			return m, nil
+		}
+
+		// Handle the selection summary if active
+		if m.showSummary {
+			return m, m.updateSelectionSummary(msg)
+		}
+
+		// Handle the git status menu if active
+		if m.showGitStatus {
+			return m, m.updateGitStatusMenu(msg)
+		}
+
 		// Handle bookmarks menu if active
 		if m.showBookmarksMenu {
 			switch msg.String() {
@@ -879,7 +3296,18 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "enter":
 				// Apply selected bookmark
 				if name, ok := m.bookmarksMenu.SelectedBookmark(); ok {
-					err := m.applyBookmark(name)
+					err := m.applyBookmark(name, true)
+					if err != nil {
+						m.addError(err)
+					}
+					m.showBookmarksMenu = false
+				}
+				return m, nil
+
+			case "a":
+				// Add the bookmark's files to the current selection
+				if name, ok := m.bookmarksMenu.SelectedBookmark(); ok {
+					err := m.applyBookmark(name, false)
 					if err != nil {
 						m.addError(err)
 					}
@@ -915,6 +3343,28 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.showRenameBookmarkDialog()
 				return m, nil
 
+			case " ":
+				// Mark the selected bookmark for merging
+				m.bookmarksMenu.ToggleMark()
+				return m, nil
+
+			case "m":
+				// Merge the marked bookmarks into a new one
+				names := m.bookmarksMenu.MarkedBookmarks()
+				if len(names) < 2 {
+					m.setStatusMessage("Mark at least two bookmarks with Space to merge them", 2)
+					return m, nil
+				}
+				m.tempMergeNames = names
+				m.textInputModal = ui.NewTextInputModal(
+					fmt.Sprintf("Name for the merge of %s", strings.Join(names, ", ")),
+					"merged",
+					m.termWidth/2,
+				)
+				m.showTextInputModal = true
+				m.textInputPurpose = "merge_bookmarks"
+				return m, nil
+
 			case "i":
 				// Add/edit description for the bookmark
 				if name, ok := m.bookmarksMenu.SelectedBookmark(); ok {
@@ -943,13 +3393,26 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Handle filtering state separately
 		if m.list.FilterState() == list.Filtering {
 			switch msg.String() {
+			default:
+				// Names are filtered right away, content once typing pauses
+				if m.contentSearchMode {
+					m.stopLiveContentSearch()
+					m.list, cmd = m.list.Update(msg)
+					m.contentSearchSeq++
+					seq := m.contentSearchSeq
+					tick := tea.Tick(contentSearchDebounce, func(time.Time) tea.Msg {
+						return contentSearchTickMsg{seq: seq}
+					})
+					return m, tea.Batch(cmd, tick)
+				}
+
 			case "up":
 				if m.searchHistoryIndex > 0 {
 					m.searchHistoryIndex--
 					// Since we can't set the filter directly, we'll apply our custom search
 					// on the current search history item
 					if len(m.searchHistory) > 0 {
-						m.performSearch(m.searchHistory[m.searchHistoryIndex])
+						return m, m.searchAll(m.searchHistory[m.searchHistoryIndex], false)
 					}
 				}
 				return m, nil
@@ -959,12 +3422,19 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.searchHistoryIndex++
 					// Apply search with history item
 					if len(m.searchHistory) > 0 {
-						m.performSearch(m.searchHistory[m.searchHistoryIndex])
+						return m, m.searchAll(m.searchHistory[m.searchHistoryIndex], false)
 					}
 				}
 				return m, nil
 
 			case "enter", "esc":
+				if msg.String() == "esc" && m.contentScan != nil {
+					// The first Esc only cancels the search in flight
+					m.stopLiveContentSearch()
+					m.setStatusMessage("Content search cancelled", 2)
+					return m, nil
+				}
+				m.stopLiveContentSearch()
 				query := m.list.FilterValue()
 				if query != "" && (len(m.searchHistory) == 0 || m.searchHistory[len(m.searchHistory)-1] != query) {
 					m.searchHistory = append(m.searchHistory, query)
@@ -973,8 +3443,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				// Perform search instead of default behavior
 				if msg.String() == "enter" {
-					m.performSearch(query)
-					return m, nil
+					return m, m.searchAll(query, true)
 				}
 			}
 		} else {
@@ -984,13 +3453,11 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				// After update, check if the filter changed and perform our custom search
 				query := m.list.FilterValue()
-				m.performSearch(query)
-
-				return m, cmd
+				return m, tea.Batch(cmd, m.searchAll(query, false))
 			}
 
-			// Regular key handling
-			switch msg.String() {
+			// Regular key handling, with custom bindings mapped to their default keys
+			switch m.keys.resolve(msg.String()) {
 			case "q", "ctrl+c":
 				return m, tea.Quit
 
@@ -1008,6 +3475,10 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 				m.toggleSelection(selectedItem.Path)
+				// Folders select their contents in bulk, which can skip large files
+				if note := m.skippedLargeNote(); note != "" {
+					m.setStatusMessage("Selected "+selectedItem.Name+note, 3)
+				}
 				return m, nil
 
 			case "ctrl+/":
@@ -1018,8 +3489,8 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.toggleContentSearchMode()
 				return m, nil
 
-			case "ctrl+a": // Select all visible
-				m.selectAll()
+			case "ctrl+a": // Select all visible, after confirming the breakdown
+				m.showSelectAllDialog()
 				return m, nil
 
 			case "ctrl+d": // Deselect all
@@ -1050,50 +3521,269 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
-			case "enter":
-				var selected []ui.FileItem
-				for _, item := range m.items {
-					if item.Selected && !m.isGitIgnored(item.Path) {
-						selected = append(selected, item)
-					}
+			case "a": // Attach a note to the highlighted file
+				selectedItem, ok := m.list.SelectedItem().(ui.FileItem)
+				if !ok || selectedItem.IsDir {
+					return m, nil
 				}
-				if len(selected) == 0 {
-					if sel, ok := m.list.SelectedItem().(ui.FileItem); ok && !m.isGitIgnored(sel.Path) {
-						selected = append(selected, sel)
-					}
+				m.showNoteDialog(selectedItem)
+				return m, nil
+
+			case "L": // Limit the highlighted file to a line range
+				selectedItem, ok := m.list.SelectedItem().(ui.FileItem)
+				if !ok || selectedItem.IsDir {
+					return m, nil
+				}
+				m.showLineRangeDialog(selectedItem)
+				return m, nil
+
+			case "D": // Expand folders one level deeper, then collapse them all
+				m.cycleExpandDepth()
+				return m, nil
+
+			case "g": // Jump to a path typed in
+				m.showGoToPathDialog()
+				return m, nil
+
+			case "R": // Pick a line range of the highlighted file in the preview pane
+				selectedItem, ok := m.list.SelectedItem().(ui.FileItem)
+				if !ok || selectedItem.IsDir {
+					return m, nil
+				}
+				if err := m.startRangePicker(selectedItem); err != nil {
+					m.addError(err)
+				}
+				return m, nil
+
+			case "n": // Jump to the next search match
+				m.jumpToSearchMatch(1)
+				return m, nil
+
+			case "N": // Jump to the previous search match
+				m.jumpToSearchMatch(-1)
+				return m, nil
+
+			case "x": // Select or deselect files by a typed extension or suffix
+				m.showExtensionDialog(true)
+				return m, nil
+
+			case "e": // Toggle all files with the highlighted file's extension
+				selectedItem, ok := m.list.SelectedItem().(ui.FileItem)
+				if !ok {
+					return m, nil
+				}
+				m.toggleSameExtension(selectedItem)
+				return m, nil
+
+			case "Y": // Copy just the selected paths
+				if err := m.copySelectedPaths(); err != nil {
+					m.addError(err)
 				}
+				return m, nil
+
+			case "C": // Copy a command that regenerates this output
+				if err := m.copySelectionCommand(); err != nil {
+					m.addError(err)
+				}
+				return m, nil
+
+			case "m": // Select recently modified files
+				m.textInputModal = ui.NewTextInputModal(
+					"Select Files Modified Within (e.g. 2h, 3d)",
+					"24h",
+					m.termWidth/2,
+				)
+				m.showTextInputModal = true
+				m.textInputPurpose = "modified_within"
+				return m, nil
+
+			case "ctrl+r": // Clear the preview cache and reload the current preview
+				ui.ClearPreviewCache()
+				ui.ClearDirCountCache()
+				ui.ClearFileInfoCache()
+				if sel, ok := m.list.SelectedItem().(ui.FileItem); ok {
+					m.preview = m.loadPreview(sel)
+				}
+				m.setStatusMessage("Preview cache cleared", 2)
+				return m, nil
+
+			case "w": // Show which gitignore rule hides the highlighted item
+				selectedItem, ok := m.list.SelectedItem().(ui.FileItem)
+				if !ok {
+					return m, nil
+				}
+				m.showGitignoreRule(selectedItem)
+				return m, nil
+
+			case "T": // Toggle token badges in the file list
+				m.config.TokenBadges = !m.config.TokenBadges
+				m.list.SetDelegate(newItemDelegate(m.config, m.cwd))
+				if m.config.TokenBadges {
+					m.setStatusMessage("Showing token estimates", 2)
+				} else {
+					m.setStatusMessage("Hiding token estimates", 2)
+				}
+				return m, nil
+
+			case "P": // Toggle relative paths in the file list
+				m.config.RelativePaths = !m.config.RelativePaths
+				m.list.SetDelegate(newItemDelegate(m.config, m.cwd))
+				if m.config.RelativePaths {
+					m.setStatusMessage("Showing relative paths", 2)
+				} else {
+					m.setStatusMessage("Showing names only", 2)
+				}
+				return m, nil
 
-				if len(selected) == 0 {
+			case "o": // Show only the selection
+				if !m.selectedOnly && m.selectedCount == 0 {
 					m.setStatusMessage("No files selected!", 2)
 					return m, nil
 				}
+				m.selectedOnly = !m.selectedOnly
+				m.refreshVisibleItems()
+				if m.selectedOnly {
+					m.setStatusMessage("Showing only selected items (o to show all)", 2)
+				} else {
+					m.setStatusMessage("Showing all items", 2)
+				}
+				return m, nil
+
+			case "t": // Toggle the output tree preview for folders
+				m.treePreview = !m.treePreview
+				if sel, ok := m.list.SelectedItem().(ui.FileItem); ok {
+					m.preview = m.loadPreview(sel)
+				}
+				if m.treePreview {
+					m.setStatusMessage("Folder preview: output tree", 2)
+				} else {
+					m.setStatusMessage("Folder preview: listing", 2)
+				}
+				return m, nil
+
+			case "v": // Toggle rendered markdown in the preview
+				m.renderMarkdown = !m.renderMarkdown
+				if sel, ok := m.list.SelectedItem().(ui.FileItem); ok {
+					m.preview = m.loadPreview(sel)
+				}
+				if m.renderMarkdown {
+					m.setStatusMessage("Markdown preview: rendered", 2)
+				} else {
+					m.setStatusMessage("Markdown preview: raw", 2)
+				}
+				return m, nil
+
+			case "S": // Review the selection before copying
+				m.openSelectionSummary(false)
+				return m, nil
+
+			case "ctrl+g": // Pick files by git status
+				m.openGitStatusMenu()
+				return m, nil
 
-				output := BuildOutput(selected, m.cwd)
-				err := clipboard.WriteAll(output)
-				if err != nil {
-					m.addError(fmt.Errorf("Failed to copy to clipboard: %v", err))
+			case "y": // Copy and keep the app open
+				if m.config.ReviewBeforeCopy {
+					m.openSelectionSummary(false)
 					return m, nil
 				}
+				m.copyAndStay()
+				return m, nil
 
-				fmt.Printf("\nFetched %d items! 🐕 Woof!\n", len(selected))
-				return m, tea.Quit
+			case "enter":
+				if m.config.ReviewBeforeCopy {
+					m.openSelectionSummary(true)
+					return m, nil
+				}
+				return m, m.confirmSelection()
 			}
 		}
 
 	case tea.WindowSizeMsg:
 		m.termWidth = msg.Width
 		m.termHeight = msg.Height
-		m.list.SetWidth(msg.Width / 2)
-		m.list.SetHeight(msg.Height - 5)
+		m.list.SetWidth(max(msg.Width/2, 1))
+		m.list.SetHeight(max(msg.Height-5, 1))
 	}
 
 	m.list, cmd = m.list.Update(msg)
 	if sel, ok := m.list.SelectedItem().(ui.FileItem); ok {
-		m.preview = ui.LoadPreview(sel.Path, sel.IsDir, m.config.MaxPreviewSize)
+		m.preview = m.loadPreview(sel)
 	}
 	return m, cmd
 }
 
+// showGitignoreRule reports the .gitignore patterns that match item
+func (m *Model) showGitignoreRule(item ui.FileItem) {
+	if !m.isGitIgnored(item.Path) {
+		m.setStatusMessage(fmt.Sprintf("%s is not gitignored", item.Name), 2)
+		return
+	}
+
+	rules, err := git.ParseGitignoreRules(filepath.Join(m.cwd, ".gitignore"))
+	var exportRules, dockerRules []git.GitignoreRule
+	if m.config.ExportIgnore {
+		exportRules, _ = git.ParseExportIgnoreRules(filepath.Join(m.cwd, ".gitattributes"))
+	}
+	if m.config.DockerIgnore {
+		dockerRules, _ = git.ParseGitignoreRules(filepath.Join(m.cwd, ".dockerignore"))
+	}
+	if len(rules) == 0 && len(exportRules) == 0 && len(dockerRules) == 0 && err != nil {
+		m.addError(err)
+		return
+	}
+
+	var matched []string
+	for _, rule := range git.MatchingRules(rules, item.Path) {
+		matched = append(matched, fmt.Sprintf(".gitignore:%d: %s", rule.Line, rule.Pattern))
+	}
+	for _, rule := range git.MatchingRules(exportRules, item.Path) {
+		matched = append(matched, fmt.Sprintf(".gitattributes:%d: %s export-ignore", rule.Line, rule.Pattern))
+	}
+	for _, rule := range git.MatchingRules(dockerRules, item.Path) {
+		matched = append(matched, fmt.Sprintf(".dockerignore:%d: %s", rule.Line, rule.Pattern))
+	}
+	if len(matched) == 0 {
+		m.setStatusMessage(fmt.Sprintf("%s is gitignored, but no rule matches it anymore", item.Name), 3)
+		return
+	}
+	m.setStatusMessage(fmt.Sprintf("%s ignored by %s", item.Name, strings.Join(matched, ", ")), 5)
+}
+
+// loadPreview loads the preview for item, rendering markdown files when enabled
+func (m *Model) loadPreview(item ui.FileItem) string {
+	if m.treePreview && item.IsDir {
+		// Exactly what the structure section will show for this folder
+		tree := buildNestedStructure([]ui.FileItem{item}, m.cwd, m.config)
+		if tree == "" {
+			tree = "(empty folder, left out of the output)\n"
+		}
+		return "Output tree:\n\n" + tree
+	}
+	if m.config.SafeMode && !item.IsDir {
+		return ui.LoadInfoPreview(item.Path)
+	}
+	if m.renderMarkdown && !item.IsDir && ui.IsMarkdownFile(item.Path) {
+		// Match the preview pane width used by View
+		width := m.termWidth - m.termWidth*2/3 - 8
+		if width < 20 {
+			width = 20
+		}
+		return ui.LoadMarkdownPreview(item.Path, m.config.MaxPreviewSize, m.config.PreviewLines, width)
+	}
+	return ui.LoadPreview(item.Path, item.IsDir, m.config.MaxPreviewSize, m.config.PreviewLines)
+}
+
+// clipboardRetryDelay is the wait before the first clipboard retry, doubled
+// for each further retry
+const clipboardRetryDelay = 100 * time.Millisecond
+
+// Below these sizes View only asks for a larger terminal
+const (
+	minTermWidth    = 40
+	minTermHeight   = 10
+	minPreviewWidth = 20
+)
+
 // View renders the UI
 // View renders the UI
 func (m *Model) View() string {
@@ -1101,48 +3791,87 @@ func (m *Model) View() string {
 		return fmt.Sprintf("%s %s", m.spinner.View(), m.loadingMessage)
 	}
 
+	// The layout math below breaks down on tiny terminals, such as split panes
+	if m.termWidth > 0 && (m.termWidth < minTermWidth || m.termHeight < minTermHeight) {
+		return fmt.Sprintf("Terminal too small (%dx%d)\nResize to at least %dx%d, or press q to quit",
+			m.termWidth, m.termHeight, minTermWidth, minTermHeight)
+	}
+
+	// Calculate appropriate widths
+	listWidth := m.termWidth * 2 / 3            // File list gets 2/3 of width
+	previewWidth := m.termWidth - listWidth - 4 // Preview gets remaining space
+
 	// Base view creation
 	var mainView string
-	if !m.showPreview {
-		mainView = ui.RenderHeader("llmdog") + "\n" +
-			m.list.View() + "\n" +
-			m.renderStatusBar()
+	if m.rangePicker != nil && previewWidth < minPreviewWidth {
+		// No room next to the list, so the picker takes its place
+		mainView = ui.RenderHeader(m.header) + "\n" +
+			m.rangePicker.view(m.termWidth-2, m.rangePickerHeight())
+	} else if (!m.showPreview && m.rangePicker == nil) || previewWidth < minPreviewWidth {
+		mainView = ui.RenderHeader(m.header) + "\n" +
+			m.list.View()
 	} else {
-		// Calculate appropriate widths
-		listWidth := m.termWidth * 2 / 3            // File list gets 2/3 of width
-		previewWidth := m.termWidth - listWidth - 4 // Preview gets remaining space
-
 		m.list.SetWidth(listWidth)
 		previewStyle := ui.PreviewStyle.MaxWidth(previewWidth).MaxHeight(m.termHeight - 6)
 
 		leftPanel := m.list.View()
 		rightPanel := previewStyle.Render(ui.TruncatePreview(m.preview, m.termHeight-8))
+		if m.rangePicker != nil {
+			// Border and padding take 6 columns
+			rightPanel = previewStyle.Render(m.rangePicker.view(previewWidth-6, m.rangePickerHeight()))
+		}
 
-		mainView = ui.RenderHeader("llmdog") + "\n" +
+		mainView = ui.RenderHeader(m.header) + "\n" +
 			lipgloss.JoinHorizontal(lipgloss.Top, leftPanel, rightPanel)
 	}
 
-	// Show text input modal if active
-	if m.showTextInputModal {
+	// Show text input modal if active
+	if m.showTextInputModal {
+		mainView = lipgloss.Place(
+			m.termWidth,
+			m.termHeight-2, // Account for status bar
+			lipgloss.Center,
+			lipgloss.Center,
+			m.textInputModal.View(),
+			lipgloss.WithWhitespaceChars(" "),
+			lipgloss.WithWhitespaceForeground(lipgloss.Color("240")),
+		)
+	}
+
+	// Show bookmarks menu if active
+	if m.showBookmarksMenu {
+		mainView = lipgloss.Place(
+			m.termWidth,
+			m.termHeight-2, // Account for status bar
+			lipgloss.Center,
+			lipgloss.Center,
+			m.bookmarksMenu.View(),
+			lipgloss.WithWhitespaceChars(" "),
+			lipgloss.WithWhitespaceForeground(lipgloss.Color("240")),
+		)
+	}
+
+	// Show selection summary if active
+	if m.showSummary {
 		mainView = lipgloss.Place(
 			m.termWidth,
 			m.termHeight-2, // Account for status bar
 			lipgloss.Center,
 			lipgloss.Center,
-			m.textInputModal.View(),
+			m.summary.View(),
 			lipgloss.WithWhitespaceChars(" "),
 			lipgloss.WithWhitespaceForeground(lipgloss.Color("240")),
 		)
 	}
 
-	// Show bookmarks menu if active
-	if m.showBookmarksMenu {
+	// Show git status menu if active
+	if m.showGitStatus {
 		mainView = lipgloss.Place(
 			m.termWidth,
 			m.termHeight-2, // Account for status bar
 			lipgloss.Center,
 			lipgloss.Center,
-			m.bookmarksMenu.View(),
+			m.gitStatusMenu.View(),
 			lipgloss.WithWhitespaceChars(" "),
 			lipgloss.WithWhitespaceForeground(lipgloss.Color("240")),
 		)
@@ -1164,6 +3893,16 @@ func (m *Model) View() string {
 }
 
 func (m *Model) renderStatusBar() string {
+	// A running content search shows its progress
+	if m.contentScan != nil {
+		return lipgloss.NewStyle().
+			Background(lipgloss.Color("205")).
+			Foreground(lipgloss.Color("255")).
+			Padding(0, 1).
+			Width(m.termWidth).
+			Render(m.contentScan.view(m.termWidth - 2))
+	}
+
 	// Show status message if it's active
 	if m.statusMessage != "" && time.Now().Before(m.statusMessageExpiry) {
 		return lipgloss.NewStyle().
@@ -1186,7 +3925,11 @@ func (m *Model) renderStatusBar() string {
 	// Help part
 	var helpText string
 	if m.showBookmarksMenu {
-		helpText = "Enter:Apply • n:New • d:Delete • r:Rename • Esc:Close"
+		helpText = "Enter:Apply • a:Append • n:New • d:Delete • r:Rename • Esc:Close"
+	} else if m.showSummary {
+		helpText = "Space:Toggle • s:Structure • Enter:Copy • Esc:Cancel"
+	} else if m.showGitStatus {
+		helpText = "Space:Toggle file or category • Enter:Apply • Esc:Close"
 	} else {
 		helpText = "Tab:Select • Ctrl+B:Bookmarks • Ctrl+S:Search Mode"
 	}
@@ -1198,6 +3941,25 @@ func (m *Model) renderStatusBar() string {
 	} else {
 		modeText += "Filename Search"
 	}
+	if m.config.SafeMode {
+		modeText += " (safe)"
+	}
+	if m.selectedOnly {
+		modeText += " • Selected only"
+	}
+	if len(m.projectProfiles) > 0 {
+		modeText += " • Project: " + strings.Join(m.projectProfiles, ", ")
+	}
+
+	// Narrow terminals only have room for the stats
+	if m.termWidth < 90 {
+		return lipgloss.NewStyle().
+			Background(lipgloss.Color("240")).
+			Foreground(lipgloss.Color("255")).
+			Width(m.termWidth).
+			MaxWidth(m.termWidth).
+			Render(statsText)
+	}
 
 	// Combine everything
 	statusBar := lipgloss.JoinHorizontal(lipgloss.Center,
@@ -1305,23 +4067,18 @@ func (m *Model) executeCustomSearch(query string) {
 		// Search file contents for the query
 		for i := range m.items {
 			if !m.items[i].IsDir && !resultPaths[m.items[i].Path] {
-				// Only check smaller files to avoid performance issues
-				info, err := os.Stat(m.items[i].Path)
-				if err == nil && info.Size() < 1024*1024 { // Skip files larger than 1MB
-					content, err := os.ReadFile(m.items[i].Path)
-					if err == nil && strings.Contains(strings.ToLower(string(content)), queryLower) {
-						// Mark as content match for UI highlighting
-						fileItem := m.items[i]
-						fileItem.MatchesContent = true
-
-						// Add to results
-						resultPaths[fileItem.Path] = true
-						results = append(results, fileItem)
-						matchCount++
-
-						// Make sure all parent directories are expanded and visible
-						addParentDirs(fileItem.Path, m.cwd, &results, &resultPaths, m.items)
-					}
+				if fileContains(m.items[i].Path, queryLower) {
+					// Mark as content match for UI highlighting
+					fileItem := m.items[i]
+					fileItem.MatchesContent = true
+
+					// Add to results
+					resultPaths[fileItem.Path] = true
+					results = append(results, fileItem)
+					matchCount++
+
+					// Make sure all parent directories are expanded and visible
+					addParentDirs(fileItem.Path, m.cwd, &results, &resultPaths, m.items)
 				}
 			}
 		}
@@ -1349,6 +4106,182 @@ func (m *Model) executeCustomSearch(query string) {
 	}
 }
 
+// Output returns the most recent output copied to the clipboard, if any
+func (m *Model) Output() string {
+	return m.lastOutput
+}
+
+// Confirmed reports whether the selection was confirmed with Enter
+func (m *Model) Confirmed() bool {
+	return m.confirmed
+}
+
+// selectedForOutput returns the selected items, falling back to the highlighted item
+func (m *Model) selectedForOutput() []ui.FileItem {
+	var selected []ui.FileItem
+	for _, item := range m.items {
+		if item.Selected && !m.isGitIgnored(item.Path) {
+			selected = append(selected, item)
+		}
+	}
+	if len(selected) == 0 {
+		if sel, ok := m.list.SelectedItem().(ui.FileItem); ok && !m.isGitIgnored(sel.Path) {
+			selected = append(selected, sel)
+		}
+	}
+	return selected
+}
+
+// copySelection builds the output for the selection and copies it to the clipboard.
+// It reports the number of items copied and whether the copy succeeded.
+func (m *Model) copySelection() (int, bool) {
+	selected := m.selectedForOutput()
+	if len(selected) == 0 {
+		m.setStatusMessage("No files selected!", 2)
+		return 0, false
+	}
+
+	// The README counts towards the budget like any selected file
+	selected = m.withReadme(selected)
+	selected, m.droppedFiles = fitTokenBudget(selected, m.cwd, m.maxTokens)
+	m.lastStats = outputStats{}
+	output := buildOutput(selected, m.cwd, m.config, &m.lastStats)
+	m.lastOutput = output
+	m.lastItems = selected
+
+	// Kept so confirming can still hand the output over on stdout
+	m.clipboardFailed = false
+	if err := m.writeClipboard(output); err != nil {
+		m.clipboardFailed = true
+		m.addError(fmt.Errorf("Failed to copy to clipboard: %v", err))
+		return 0, false
+	}
+
+	return len(selected), true
+}
+
+// writeClipboard copies text to the clipboard, retrying with a growing delay
+// since clipboard tools sometimes fail while the display server is busy
+func (m *Model) writeClipboard(text string) error {
+	delay := clipboardRetryDelay
+	err := clipboard.WriteAll(text)
+	for attempt := 1; err != nil && attempt <= m.config.ClipboardRetries; attempt++ {
+		slog.Debug("clipboard write failed, retrying", "attempt", attempt, "err", err)
+		time.Sleep(delay)
+		delay *= 2
+		err = clipboard.WriteAll(text)
+	}
+	return err
+}
+
+// OutputFiles returns the number of files in the last output
+func (m *Model) OutputFiles() int {
+	count := 0
+	for _, item := range m.lastItems {
+		if !item.IsDir {
+			count++
+		}
+	}
+	return count
+}
+
+// Costs estimates what count input tokens cost with each model in the
+// modelPrices config key
+func (m *Model) Costs(count int) []string {
+	return tokens.Costs(count, m.config.ModelPrices)
+}
+
+// SingleFile reports whether a single file was opened instead of a directory
+func (m *Model) SingleFile() bool {
+	return m.singleFile
+}
+
+// CopyOutput copies the output for the selection to the clipboard without the
+// TUI. The output is kept even when the copy fails, so it can be printed instead.
+func (m *Model) CopyOutput() error {
+	if _, ok := m.copySelection(); !ok {
+		if m.clipboardFailed {
+			return fmt.Errorf("could not copy to the clipboard")
+		}
+		return fmt.Errorf("no files selected")
+	}
+	return nil
+}
+
+// ClipboardFailed reports whether the last output couldn't be copied to the
+// clipboard, in which case a confirmed output should be printed instead
+func (m *Model) ClipboardFailed() bool {
+	return m.clipboardFailed
+}
+
+// OverTokenLimit returns the files the last output left out for exceeding the
+// maxFileTokens config key
+func (m *Model) OverTokenLimit() []string {
+	return m.lastStats.overTokenLimit
+}
+
+// DroppedFiles returns the files left out of the last output to fit --max-tokens
+func (m *Model) DroppedFiles() []string {
+	return m.droppedFiles
+}
+
+// fitTokenBudget drops the largest files from items until their estimated
+// token count fits within budget. It returns the kept items and the relative
+// paths of the dropped files. A budget of 0 keeps everything.
+func fitTokenBudget(items []ui.FileItem, cwd string, budget int) ([]ui.FileItem, []string) {
+	if budget <= 0 {
+		return items, nil
+	}
+
+	type fileTokens struct {
+		path   string
+		tokens int
+	}
+	var files []fileTokens
+	total := 0
+	for _, item := range items {
+		if item.IsDir {
+			continue
+		}
+		if info, err := os.Stat(item.Path); err == nil && info.Mode().IsRegular() {
+			estimate := tokens.EstimateSize(info.Size())
+			files = append(files, fileTokens{item.Path, estimate})
+			total += estimate
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].tokens > files[j].tokens
+	})
+
+	drop := make(map[string]bool)
+	for _, file := range files {
+		if total <= budget {
+			break
+		}
+		drop[file.path] = true
+		total -= file.tokens
+	}
+	if len(drop) == 0 {
+		return items, nil
+	}
+
+	var kept []ui.FileItem
+	var dropped []string
+	for _, item := range items {
+		if !drop[item.Path] {
+			kept = append(kept, item)
+			continue
+		}
+		rel, err := filepath.Rel(cwd, item.Path)
+		if err != nil {
+			rel = item.Path
+		}
+		dropped = append(dropped, rel)
+	}
+	return kept, dropped
+}
+
 // addParentDirs adds all parent directories of a path to the results
 func addParentDirs(path, rootPath string, results *[]list.Item, resultPaths *map[string]bool, allItems []ui.FileItem) {
 	// Get the parent directory path
@@ -1376,12 +4309,12 @@ func addParentDirs(path, rootPath string, results *[]list.Item, resultPaths *map
 	}
 }
 
-func (m *Model) saveCurrentSelectionAsBookmark(name, description string) error {
+// selectedRelativePaths returns the selected paths relative to the current working directory
+func (m *Model) selectedRelativePaths() []string {
 	var selectedPaths []string
 
 	for _, item := range m.items {
 		if item.Selected && !m.isGitIgnored(item.Path) {
-			// Store paths relative to the current working directory
 			relPath, err := filepath.Rel(m.cwd, item.Path)
 			if err == nil {
 				selectedPaths = append(selectedPaths, relPath)
@@ -1391,6 +4324,100 @@ func (m *Model) saveCurrentSelectionAsBookmark(name, description string) error {
 		}
 	}
 
+	return selectedPaths
+}
+
+// copySelectedPaths copies the selected relative paths, one per line, to the clipboard
+func (m *Model) copySelectedPaths() error {
+	selectedPaths := m.selectedRelativePaths()
+	if len(selectedPaths) == 0 {
+		return fmt.Errorf("no files selected")
+	}
+
+	if err := m.writeClipboard(strings.Join(selectedPaths, "\n") + "\n"); err != nil {
+		return fmt.Errorf("Failed to copy to clipboard: %v", err)
+	}
+
+	m.setStatusMessage(fmt.Sprintf("Copied %d paths to clipboard", len(selectedPaths)), 2)
+	return nil
+}
+
+// selectionCommand builds an llmdog --no-tui command selecting the same files,
+// to run from the working directory. Selected folders stand for their
+// contents. It returns the command and the number of paths it names.
+func (m *Model) selectionCommand() (string, int) {
+	selectedDirs := make(map[string]bool)
+	for _, item := range m.items {
+		if item.IsDir && item.Selected {
+			selectedDirs[item.Path] = true
+		}
+	}
+
+	args := []string{"llmdog", "--no-tui"}
+	count := 0
+	for _, item := range m.items {
+		if !item.Selected || m.isGitIgnored(item.Path) || hasSelectedAncestor(item.Path, m.cwd, selectedDirs) {
+			continue
+		}
+		rel, err := filepath.Rel(m.cwd, item.Path)
+		if err != nil {
+			continue
+		}
+		// --select takes globs, so characters special to them are escaped
+		pattern := globEscaper.Replace(filepath.ToSlash(rel))
+		args = append(args, "--select", shellQuote(pattern))
+		count++
+	}
+
+	// Paths are relative to the root, so a root other than the working directory is named
+	if wd, err := os.Getwd(); err == nil && wd != m.cwd {
+		root, err := filepath.Rel(wd, m.cwd)
+		if err != nil {
+			root = m.cwd
+		}
+		args = append(args, shellQuote(root))
+	}
+	return strings.Join(args, " "), count
+}
+
+// hasSelectedAncestor reports whether a folder containing path, below root, is selected
+func hasSelectedAncestor(path, root string, selectedDirs map[string]bool) bool {
+	for dir := filepath.Dir(path); dir != root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if selectedDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// globEscaper escapes the characters matchGlob treats specially
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// copySelectionCommand copies a command that regenerates the output for the
+// selection to the clipboard
+func (m *Model) copySelectionCommand() error {
+	command, count := m.selectionCommand()
+	if count == 0 {
+		return fmt.Errorf("no files selected")
+	}
+
+	if err := m.writeClipboard(command + "\n"); err != nil {
+		return fmt.Errorf("Failed to copy to clipboard: %v", err)
+	}
+
+	m.setStatusMessage(fmt.Sprintf("Copied llmdog command selecting %d paths", count), 2)
+	return nil
+}
+
+func (m *Model) saveCurrentSelectionAsBookmark(name, description string) error {
+	// Store paths relative to the current working directory
+	selectedPaths := m.selectedRelativePaths()
+
 	if len(selectedPaths) == 0 {
 		return fmt.Errorf("no files selected")
 	}
@@ -1404,18 +4431,41 @@ func (m *Model) saveCurrentSelectionAsBookmark(name, description string) error {
 		Modified:    time.Now(),
 	}
 
+	// Keep file notes with the bookmark
+	for _, item := range m.items {
+		if item.Selected && item.Note != "" {
+			if rel, err := filepath.Rel(m.cwd, item.Path); err == nil {
+				if bookmark.Notes == nil {
+					bookmark.Notes = make(map[string]string)
+				}
+				bookmark.Notes[rel] = item.Note
+			}
+		}
+	}
+
+	// Overwriting keeps the original bookmark's creation date and description
+	if existing, found := m.bookmarkStore.GetBookmark(name); found {
+		bookmark.Created = existing.Created
+		if description == "" {
+			bookmark.Description = existing.Description
+		}
+	}
+
 	return m.bookmarkStore.SaveBookmark(bookmark)
 }
 
-// applyBookmark applies a saved bookmark selection
-func (m *Model) applyBookmark(name string) error {
+// applyBookmark applies a saved bookmark selection, either replacing the
+// current selection or adding to it
+func (m *Model) applyBookmark(name string, replace bool) error {
 	bookmark, found := m.bookmarkStore.GetBookmark(name)
 	if !found {
 		return fmt.Errorf("bookmark not found: %s", name)
 	}
 
 	// Reset current selection
-	m.deselectAll()
+	if replace {
+		m.deselectAll()
+	}
 
 	// Apply bookmark selection
 	for _, relPath := range bookmark.FilePaths {
@@ -1426,6 +4476,9 @@ func (m *Model) applyBookmark(name string) error {
 		for i := range m.items {
 			if m.items[i].Path == absPath {
 				m.toggleSelection(absPath, true)
+				if note := bookmark.Notes[relPath]; note != "" {
+					m.items[i].Note = note
+				}
 
 				// Ensure parent directories are expanded to make the item visible
 				m.ensureParentPathsExpanded(absPath)
@@ -1435,7 +4488,11 @@ func (m *Model) applyBookmark(name string) error {
 	}
 
 	m.refreshVisibleItems()
-	m.setStatusMessage(fmt.Sprintf("Applied bookmark: %s", name), 2)
+	if replace {
+		m.setStatusMessage(fmt.Sprintf("Applied bookmark: %s%s", name, m.skippedLargeNote()), 2)
+	} else {
+		m.setStatusMessage(fmt.Sprintf("Added bookmark to selection: %s%s", name, m.skippedLargeNote()), 2)
+	}
 	return nil
 }
 
@@ -1450,6 +4507,52 @@ func (m *Model) deleteBookmark(name string) error {
 	return nil
 }
 
+// mergeBookmarks saves the combined files and notes of several bookmarks as a
+// new bookmark, without duplicates. It returns the number of files.
+func (m *Model) mergeBookmarks(names []string, newName string) (int, error) {
+	if _, exists := m.bookmarkStore.GetBookmark(newName); exists {
+		return 0, fmt.Errorf("a bookmark named %q already exists", newName)
+	}
+
+	merged := bookmarks.Bookmark{
+		Name:        newName,
+		Description: "Merged from " + strings.Join(names, ", "),
+		Created:     time.Now(),
+		Modified:    time.Now(),
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		bookmark, found := m.bookmarkStore.GetBookmark(name)
+		if !found {
+			return 0, fmt.Errorf("bookmark not found: %s", name)
+		}
+		// Paths are relative to the root, so bookmarks of other directories don't mix
+		if merged.RootPath == "" {
+			merged.RootPath = bookmark.RootPath
+		} else if bookmark.RootPath != merged.RootPath {
+			return 0, fmt.Errorf("can't merge %s: it was saved in %s, not %s", name, bookmark.RootPath, merged.RootPath)
+		}
+
+		for _, path := range bookmark.FilePaths {
+			if !seen[path] {
+				seen[path] = true
+				merged.FilePaths = append(merged.FilePaths, path)
+			}
+		}
+		// The first bookmark with a note on a file wins
+		for path, note := range bookmark.Notes {
+			if merged.Notes == nil {
+				merged.Notes = make(map[string]string)
+			}
+			if _, ok := merged.Notes[path]; !ok {
+				merged.Notes[path] = note
+			}
+		}
+	}
+
+	return len(merged.FilePaths), m.bookmarkStore.SaveBookmark(merged)
+}
+
 // renameBookmark renames a bookmark
 func (m *Model) renameBookmark(oldName, newName string) error {
 	// Get the bookmark
@@ -1458,6 +4561,13 @@ func (m *Model) renameBookmark(oldName, newName string) error {
 		return fmt.Errorf("bookmark not found: %s", oldName)
 	}
 
+	// Renaming onto another bookmark would silently replace it
+	if newName != oldName {
+		if _, exists := m.bookmarkStore.GetBookmark(newName); exists {
+			return fmt.Errorf("a bookmark named %q already exists", newName)
+		}
+	}
+
 	// Delete the old bookmark
 	err := m.bookmarkStore.DeleteBookmark(oldName)
 	if err != nil {
@@ -1477,10 +4587,224 @@ func (m *Model) showNewBookmarkDialog() {
 		"My Bookmark",
 		m.termWidth/2,
 	)
+	m.textInputModal.SetValue(m.suggestedBookmarkName())
 	m.showTextInputModal = true
 	m.textInputPurpose = "new_bookmark"
 }
 
+// suggestedBookmarkName expands the bookmark name template. {branch} is the
+// current git branch (or the directory name outside a repository), {dir}
+// the directory name and {date} today's date.
+func (m *Model) suggestedBookmarkName() string {
+	name := m.config.BookmarkNameTemplate
+	dir := filepath.Base(m.cwd)
+
+	if strings.Contains(name, "{branch}") {
+		branch := ""
+		if git.IsRepo(m.cwd) {
+			branch = git.GetBranch(m.cwd)
+		}
+		if branch == "" || branch == "HEAD" {
+			branch = dir
+		}
+		name = strings.ReplaceAll(name, "{branch}", strings.ReplaceAll(branch, "/", "-"))
+	}
+	name = strings.ReplaceAll(name, "{dir}", dir)
+	name = strings.ReplaceAll(name, "{date}", time.Now().Format("2006-01-02"))
+	return name
+}
+
+// headerTitle expands the header format, where {dir} is the directory name and
+// {branch} the current git branch. Outside a repository {branch} is empty and
+// brackets left empty around it are dropped.
+func headerTitle(format, cwd string) string {
+	title := strings.ReplaceAll(format, "{dir}", filepath.Base(cwd))
+	if strings.Contains(title, "{branch}") {
+		branch := git.GetBranch(cwd)
+		if branch == "HEAD" {
+			// Detached, there is no branch to name
+			branch = ""
+		}
+		title = strings.ReplaceAll(title, "{branch}", branch)
+		title = strings.NewReplacer("()", "", "[]", "").Replace(title)
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// showLineRangeDialog shows the dialog for limiting a file to a line range
+func (m *Model) showLineRangeDialog(item ui.FileItem) {
+	placeholder := "100-200"
+	if item.LineStart > 0 {
+		placeholder = fmt.Sprintf("%d-%d", item.LineStart, item.LineEnd)
+	}
+
+	m.tempRangePath = item.Path
+	m.textInputModal = ui.NewTextInputModal(
+		"Enter Line Range (empty for whole file)",
+		placeholder,
+		m.termWidth/2,
+	)
+	m.showTextInputModal = true
+	m.textInputPurpose = "line_range"
+}
+
+// showNoteDialog asks for a note to attach to a file
+func (m *Model) showNoteDialog(item ui.FileItem) {
+	m.tempNotePath = item.Path
+	m.textInputModal = ui.NewTextInputModal(
+		fmt.Sprintf("Note for %s (empty to remove)", item.Name),
+		"this is the buggy function",
+		m.termWidth/2,
+	)
+	m.textInputModal.SetValue(item.Note)
+	m.showTextInputModal = true
+	m.textInputPurpose = "file_note"
+}
+
+// setNote attaches a note to a file and selects it, or removes the note if it's empty
+func (m *Model) setNote(path, note string) {
+	for i := range m.items {
+		if m.items[i].Path != path || m.items[i].IsDir {
+			continue
+		}
+		name := m.items[i].Name
+		if note == "" {
+			m.items[i].Note = ""
+			m.refreshVisibleItems()
+			m.setStatusMessage(fmt.Sprintf("Removed note from %s", name), 2)
+			return
+		}
+		m.toggleSelection(path, true)
+		m.items[i].Note = note
+		m.refreshVisibleItems()
+		m.setStatusMessage(fmt.Sprintf("Added note to %s", name), 2)
+		return
+	}
+}
+
+// setLineRange limits a file's output to a line range and selects it.
+// An empty range restores the whole file.
+func (m *Model) setLineRange(path, input string) error {
+	start, end := 0, 0
+	if strings.TrimSpace(input) != "" {
+		rangePath, rangeStart, rangeEnd, err := parseLineRange(input)
+		if err != nil {
+			return err
+		}
+		if rangePath != "" {
+			path = filepath.Join(m.cwd, rangePath)
+		}
+		start, end = rangeStart, rangeEnd
+	}
+
+	for i := range m.items {
+		if m.items[i].Path == path && !m.items[i].IsDir {
+			m.items[i].LineStart = start
+			m.items[i].LineEnd = end
+			if start > 0 {
+				m.toggleSelection(path, true)
+				m.setStatusMessage(fmt.Sprintf("Limited %s to lines %d-%d", m.items[i].Name, start, end), 2)
+			} else {
+				m.refreshVisibleItems()
+				m.setStatusMessage(fmt.Sprintf("Using all of %s", m.items[i].Name), 2)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("file not found: %s", path)
+}
+
+// showOutputExistsDialog asks whether to overwrite, append to or keep an
+// existing output file
+func (m *Model) showOutputExistsDialog() {
+	m.textInputModal = ui.NewTextInputModal(
+		fmt.Sprintf("%s already exists. Overwrite, append or cancel? (o/a/c)", m.outputFile),
+		"c",
+		m.termWidth/2,
+	)
+	m.showTextInputModal = true
+	m.textInputPurpose = "output_exists"
+}
+
+// writeOutputFile writes the last output to the output file, or appends
+// it after a separator
+func (m *Model) writeOutputFile(appendOutput bool) error {
+	if !appendOutput {
+		if err := os.WriteFile(m.outputFile, []byte(m.lastOutput), 0644); err != nil {
+			return fmt.Errorf("Failed to write %s: %v", m.outputFile, err)
+		}
+		return nil
+	}
+
+	file, err := os.OpenFile(m.outputFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to append to %s: %v", m.outputFile, err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString("\n---\n\n" + m.lastOutput); err != nil {
+		return fmt.Errorf("Failed to append to %s: %v", m.outputFile, err)
+	}
+	return nil
+}
+
+// copyAndStay copies the selection and reports it in the status bar, keeping
+// the app open
+func (m *Model) copyAndStay() {
+	count, ok := m.copySelection()
+	if ok && len(m.droppedFiles) > 0 {
+		m.setStatusMessage(fmt.Sprintf("Copied %d items to clipboard, dropped %d to fit --max-tokens: %s%s",
+			count, len(m.droppedFiles), strings.Join(m.droppedFiles, ", "), m.copyNotes()), 4)
+	} else if ok {
+		m.setStatusMessage(fmt.Sprintf("Copied %d items to clipboard%s", count, m.copyNotes()), 2)
+	}
+}
+
+// confirmSelection copies the selection, writes the output file if one was
+// given and ends the session. It returns nil when the app stays open, such as
+// after a failed copy or while asking before overwriting the output file.
+func (m *Model) confirmSelection() tea.Cmd {
+	// When the clipboard fails the output is printed on exit instead
+	if _, ok := m.copySelection(); !ok && !m.clipboardFailed {
+		return nil
+	}
+
+	if m.outputFile != "" {
+		// Protect an existing file, which may hold accumulated context
+		if _, err := os.Stat(m.outputFile); err == nil && !m.forceOutput {
+			m.showOutputExistsDialog()
+			return nil
+		}
+		if err := m.writeOutputFile(false); err != nil {
+			m.addError(err)
+			return nil
+		}
+	}
+	return m.finishConfirm()
+}
+
+// finishConfirm ends the session after the selection was confirmed
+func (m *Model) finishConfirm() tea.Cmd {
+	m.confirmed = true
+	if !m.clipboardFailed {
+		fmt.Printf("\nFetched %d items%s! 🐕 Woof!\n", len(m.lastItems), m.copyNotes())
+	}
+	return tea.Quit
+}
+
+// showOverwriteBookmarkDialog asks for confirmation before replacing an existing bookmark
+func (m *Model) showOverwriteBookmarkDialog(name string) {
+	m.tempBookmarkName = name
+	m.textInputModal = ui.NewTextInputModal(
+		fmt.Sprintf("Bookmark %q already exists. Overwrite? (y/n)", name),
+		"n",
+		m.termWidth/2,
+	)
+	m.showTextInputModal = true
+	m.textInputPurpose = "overwrite_bookmark"
+}
+
 // showRenameBookmarkDialog shows the dialog for renaming a bookmark
 func (m *Model) showRenameBookmarkDialog() {
 	if name, ok := m.bookmarksMenu.SelectedBookmark(); ok {
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadTextFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+		note bool
+	}{
+		{name: "utf-16 le", data: []byte{0xff, 0xfe, 'h', 0, 'i', 0, '\n', 0}, want: "hi\n"},
+		{name: "utf-16 be", data: []byte{0xfe, 0xff, 0, 'h', 0, 'i', 0, '\n'}, want: "hi\n"},
+		{name: "utf-8", data: []byte("héllo\n"), want: "héllo\n"},
+		{name: "utf-8 with bom", data: []byte("\xef\xbb\xbfhi\n"), want: "\xef\xbb\xbfhi\n"},
+		{name: "invalid utf-8", data: []byte{'a', 0xff, 'b', 0xc3}, note: true},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".txt")
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			content, note, err := readTextFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.note {
+				if note == "" || content != nil {
+					t.Errorf("readTextFile(%q) = %q, %q, want a skip note", tt.data, content, note)
+				}
+				return
+			}
+			if note != "" || string(content) != tt.want {
+				t.Errorf("readTextFile(%q) = %q, %q, want %q", tt.data, content, note, tt.want)
+			}
+		})
+	}
+}
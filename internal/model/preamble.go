@@ -0,0 +1,79 @@
+package model
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/doganarif/llmdog/internal/git"
+	"github.com/doganarif/llmdog/internal/tokens"
+	"github.com/doganarif/llmdog/internal/ui"
+)
+
+// PreambleData is what a preamble template can refer to, such as {{.Branch}}
+type PreambleData struct {
+	Repo       string   // Name of the working directory
+	Branch     string   // Current git branch, empty outside a repository
+	LastCommit string   // Short hash, subject and age of the last commit
+	FileCount  int      // Files in the output
+	Tokens     int      // Estimated tokens of the output, without the preamble
+	Files      []string // Paths of the files relative to the working directory
+}
+
+// loadPreamble returns the preamble from the preambleFile config key, read
+// relative to cwd, or else the preamble key or the default
+func loadPreamble(config Config, cwd string) string {
+	if config.PreambleFile != "" {
+		path := ExpandPath(config.PreambleFile)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return string(data)
+		}
+		slog.Warn("could not read preamble file", "path", path, "err", err)
+	}
+	if config.Preamble != "" {
+		return config.Preamble
+	}
+	return defaultPreamble
+}
+
+// renderPreamble fills in the placeholders of preamble for the output content
+// of items. Text without placeholders, or a template that fails, is used as is.
+func renderPreamble(preamble, content string, items []ui.FileItem, cwd string) string {
+	if !strings.Contains(preamble, "{{") {
+		return preamble
+	}
+	tmpl, err := template.New("preamble").Parse(preamble)
+	if err != nil {
+		slog.Warn("preamble is not a valid template, using it as is", "err", err)
+		return preamble
+	}
+
+	data := PreambleData{
+		Repo:   filepath.Base(cwd),
+		Tokens: tokens.Estimate(content),
+	}
+	if summary, err := git.GetRepoSummary(cwd); err == nil {
+		data.Branch = summary["branch"]
+		data.LastCommit = summary["last_commit"]
+	}
+	for _, item := range items {
+		if !item.IsDir {
+			data.FileCount++
+			data.Files = append(data.Files, relSlash(cwd, item.Path))
+		}
+	}
+
+	var sb bytes.Buffer
+	if err := tmpl.Execute(&sb, data); err != nil {
+		slog.Warn("could not fill in the preamble, using it as is", "err", err)
+		return preamble
+	}
+	return sb.String()
+}
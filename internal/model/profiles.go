@@ -0,0 +1,78 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ProjectProfile excludes the directories a kind of project generates, once
+// one of its marker files is found in the working directory
+type ProjectProfile struct {
+	Markers     []string `json:"markers"`     // Files that identify the project type, such as go.mod
+	ExcludeDirs []string `json:"excludeDirs"` // Directory names never traversed in such projects
+}
+
+// defaultProjectProfiles returns the built-in project profiles
+func defaultProjectProfiles() map[string]ProjectProfile {
+	return map[string]ProjectProfile{
+		"go": {
+			Markers:     []string{"go.mod"},
+			ExcludeDirs: []string{"vendor"},
+		},
+		"node": {
+			Markers:     []string{"package.json"},
+			ExcludeDirs: []string{"node_modules", "dist", "build", "coverage", ".next"},
+		},
+		"python": {
+			Markers:     []string{"pyproject.toml", "setup.py", "requirements.txt"},
+			ExcludeDirs: []string{"__pycache__", ".venv", "venv", ".tox", ".pytest_cache", ".mypy_cache"},
+		},
+		"rust": {
+			Markers:     []string{"Cargo.toml"},
+			ExcludeDirs: []string{"target"},
+		},
+	}
+}
+
+// detectProjectProfiles returns the names of the profiles with a marker file
+// in dir, sorted
+func detectProjectProfiles(dir string, profiles map[string]ProjectProfile) []string {
+	var detected []string
+	for name, profile := range profiles {
+		for _, marker := range profile.Markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				detected = append(detected, name)
+				break
+			}
+		}
+	}
+	sort.Strings(detected)
+	return detected
+}
+
+// applyProjectProfiles adds the exclusions of the detected profiles to the
+// config and returns the names of those profiles
+func applyProjectProfiles(config *Config, dir string) []string {
+	if !config.DetectProjectType {
+		return nil
+	}
+
+	detected := detectProjectProfiles(dir, config.ProjectProfiles)
+	excluded := make(map[string]bool)
+	for _, name := range config.ExcludeDirs {
+		excluded[name] = true
+	}
+	// Copy before appending so the slice shared with the loaded config is untouched
+	excludeDirs := append([]string(nil), config.ExcludeDirs...)
+	for _, name := range detected {
+		for _, dir := range config.ProjectProfiles[name].ExcludeDirs {
+			if !excluded[dir] {
+				excluded[dir] = true
+				excludeDirs = append(excludeDirs, dir)
+			}
+		}
+	}
+	config.ExcludeDirs = excludeDirs
+	return detected
+}
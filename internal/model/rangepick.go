@@ -0,0 +1,141 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/doganarif/llmdog/internal/ui"
+)
+
+// rangePicker picks a line range of a file in the preview pane
+type rangePicker struct {
+	path   string
+	name   string
+	lines  []string
+	cursor int // Line under the cursor, 0-based
+	anchor int // Marked start of the range, 0-based, or -1 when unmarked
+	offset int // First visible line
+}
+
+var (
+	rangeLineStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
+	rangeNumberStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+// startRangePicker opens the line range picker for item, starting at its
+// current line range if it has one
+func (m *Model) startRangePicker(item ui.FileItem) error {
+	if m.config.SafeMode {
+		return fmt.Errorf("picking lines reads %s, so it's off in safe mode; use L to enter a range", item.Name)
+	}
+	content, note, err := readTextFile(item.Path)
+	if err != nil {
+		return err
+	}
+	if note != "" {
+		return fmt.Errorf("can't pick lines of %s: %s", item.Name, note)
+	}
+
+	content = transformContent(content, m.config)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+
+	picker := &rangePicker{path: item.Path, name: item.Name, lines: lines, anchor: -1}
+	if item.LineStart > 0 && item.LineStart <= len(lines) {
+		picker.anchor = item.LineStart - 1
+		picker.cursor = min(item.LineEnd, len(lines)) - 1
+	}
+	m.rangePicker = picker
+	return nil
+}
+
+// updateRangePicker handles a key press while the range picker is open
+func (m *Model) updateRangePicker(key string) {
+	p := m.rangePicker
+	page := m.rangePickerHeight()
+
+	switch key {
+	case "esc", "q":
+		m.rangePicker = nil
+		return
+	case "up", "k":
+		p.cursor--
+	case "down", "j":
+		p.cursor++
+	case "pgup", "ctrl+u":
+		p.cursor -= page
+	case "pgdown", "ctrl+d":
+		p.cursor += page
+	case "home", "g":
+		p.cursor = 0
+	case "end", "G":
+		p.cursor = len(p.lines) - 1
+	case " ": // Mark the start of the range, or clear the mark
+		if p.anchor == p.cursor {
+			p.anchor = -1
+		} else {
+			p.anchor = p.cursor
+		}
+	case "enter":
+		start, end := p.selection()
+		m.rangePicker = nil
+		if err := m.setLineRange(p.path, fmt.Sprintf("%d-%d", start, end)); err != nil {
+			m.addError(err)
+		}
+		return
+	}
+
+	p.cursor = max(0, min(p.cursor, len(p.lines)-1))
+	// Keep the cursor on screen
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+page {
+		p.offset = p.cursor - page + 1
+	}
+}
+
+// selection returns the picked range as 1-based line numbers. Without a
+// marked start, just the line under the cursor is picked.
+func (p *rangePicker) selection() (int, int) {
+	if p.anchor < 0 {
+		return p.cursor + 1, p.cursor + 1
+	}
+	return min(p.anchor, p.cursor) + 1, max(p.anchor, p.cursor) + 1
+}
+
+// rangePickerHeight returns how many file lines fit in the preview pane,
+// leaving room for the picker's header
+func (m *Model) rangePickerHeight() int {
+	return max(1, m.termHeight-14)
+}
+
+// view renders the picker for a pane of the given width and number of lines
+func (p *rangePicker) view(width, height int) string {
+	start, end := p.selection()
+
+	var sb strings.Builder
+	sb.WriteString(ui.EmphasisStyle.Render("Lines of "+p.name) + "\n")
+	sb.WriteString(fmt.Sprintf("Lines %d-%d of %d\n", start, end, len(p.lines)))
+	sb.WriteString("space mark · enter set · esc cancel\n\n")
+
+	numberWidth := len(fmt.Sprint(len(p.lines)))
+	for i := p.offset; i < len(p.lines) && i < p.offset+height; i++ {
+		line := strings.ReplaceAll(p.lines[i], "\t", "    ")
+		// Leave room for the cursor marker and line number
+		if room := width - numberWidth - 3; room > 0 && len([]rune(line)) > room {
+			line = string([]rune(line)[:room])
+		}
+
+		marker := "  "
+		if i == p.cursor {
+			marker = "> "
+		}
+		number := rangeNumberStyle.Render(fmt.Sprintf("%*d", numberWidth, i+1))
+		if i+1 >= start && i+1 <= end {
+			line = rangeLineStyle.Render(line)
+		}
+		sb.WriteString(marker + number + " " + line + "\n")
+	}
+	return sb.String()
+}
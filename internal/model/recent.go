@@ -0,0 +1,79 @@
+package model
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxRecentDirs is how many directories --recent remembers
+const maxRecentDirs = 10
+
+// RecentDir is a directory llmdog was recently browsed in, kept for --recent
+type RecentDir struct {
+	Dir      string    `json:"dir"`
+	Selected int       `json:"selected"` // Files selected when llmdog was last closed there
+	Used     time.Time `json:"used"`
+}
+
+// recentDirsPath returns the path of the file holding the recent directories
+func recentDirsPath() string {
+	return filepath.Join(filepath.Dir(globalConfigPath()), "recent.json")
+}
+
+// LoadRecentDirs loads the recent directories, most recent first, leaving out
+// those that no longer exist
+func LoadRecentDirs() ([]RecentDir, error) {
+	data, err := os.ReadFile(recentDirsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var dirs []RecentDir
+	if err := json.Unmarshal(data, &dirs); err != nil {
+		return nil, err
+	}
+	existing := dirs[:0]
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir.Dir); err == nil && info.IsDir() {
+			existing = append(existing, dir)
+		}
+	}
+	return existing, nil
+}
+
+// RecordRecentDir moves dir to the front of the recent directories with the
+// number of files selected there
+func RecordRecentDir(dir string, selected int) error {
+	dirs, err := LoadRecentDirs()
+	if err != nil {
+		// A corrupt list is started over rather than blocking every run
+		dirs = nil
+	}
+
+	recent := []RecentDir{{Dir: dir, Selected: selected, Used: time.Now()}}
+	for _, other := range dirs {
+		if other.Dir != dir && len(recent) < maxRecentDirs {
+			recent = append(recent, other)
+		}
+	}
+
+	data, err := json.MarshalIndent(recent, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := recentDirsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// RecordRecent remembers the directory of this session for --recent
+func (m *Model) RecordRecent() error {
+	return RecordRecentDir(m.cwd, m.selectedCount)
+}
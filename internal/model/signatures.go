@@ -0,0 +1,83 @@
+package model
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+// signaturesOnly reduces source code to its top-level declarations, without
+// function bodies. It reports false for unsupported languages and for code
+// that doesn't parse, so the caller can fall back to the full content.
+func signaturesOnly(content []byte, path string) ([]byte, bool) {
+	switch strings.ToLower(languageFor(path)) {
+	case "go":
+		return goSignatures(content)
+	}
+	return nil, false
+}
+
+// gofmtPrinter prints Go code the way gofmt lays it out
+var gofmtPrinter = printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+
+// goSignatures keeps the package clause, imports, type declarations, constants,
+// the names and types of variables and the signatures of functions and methods
+func goSignatures(content []byte) ([]byte, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, false
+	}
+
+	var sb strings.Builder
+	sb.WriteString("package " + file.Name.Name + "\n")
+
+	for _, decl := range file.Decls {
+		// Comments inside the declaration are kept, such as those on struct fields
+		var node any = &printer.CommentedNode{Node: decl, Comments: file.Comments}
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			d.Body = nil
+		case *ast.GenDecl:
+			if d.Tok == token.VAR && dropVarValues(d) {
+				// Comments in the dropped values would end up out of place
+				node = d
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := gofmtPrinter.Fprint(&buf, fset, node); err != nil {
+			return nil, false
+		}
+
+		sb.WriteString("\n" + buf.String() + "\n")
+	}
+
+	return []byte(sb.String()), true
+}
+
+// dropVarValues removes the initial values of variables whose type is written
+// out, either in the declaration or as a composite literal, since these are
+// often large literals. It reports whether anything was removed.
+func dropVarValues(decl *ast.GenDecl) bool {
+	dropped := false
+	for _, spec := range decl.Specs {
+		value, ok := spec.(*ast.ValueSpec)
+		if !ok || len(value.Values) == 0 {
+			continue
+		}
+		if value.Type == nil && len(value.Values) == 1 {
+			if lit, ok := value.Values[0].(*ast.CompositeLit); ok && lit.Type != nil {
+				value.Type = lit.Type
+			}
+		}
+		if value.Type != nil {
+			value.Values = nil
+			dropped = true
+		}
+	}
+	return dropped
+}
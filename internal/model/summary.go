@@ -0,0 +1,101 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/doganarif/llmdog/internal/tokens"
+	"github.com/doganarif/llmdog/internal/ui"
+)
+
+// openSelectionSummary lists the files about to be copied for a last review.
+// With confirm set, Enter in the summary confirms the selection like Enter in
+// the file list; otherwise it copies and keeps the app open.
+func (m *Model) openSelectionSummary(confirm bool) {
+	var files []ui.SummaryItem
+	for _, item := range m.selectedForOutput() {
+		if item.IsDir {
+			continue
+		}
+		info, err := os.Stat(item.Path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		rel, err := filepath.Rel(m.cwd, item.Path)
+		if err != nil {
+			rel = item.Path
+		}
+		files = append(files, ui.SummaryItem{
+			Path:    item.Path,
+			RelPath: rel,
+			Size:    info.Size(),
+			Tokens:  tokens.EstimateSize(info.Size()),
+		})
+	}
+	if len(files) == 0 {
+		m.setStatusMessage("No files selected!", 2)
+		return
+	}
+
+	m.summary = ui.NewSelectionSummary(files, m.termWidth/2, m.termHeight/2)
+	m.summary.SetPrices(m.config.ModelPrices)
+	m.summaryConfirms = confirm
+	m.showSummary = true
+	m.updateSummaryStructure()
+}
+
+// updateSummaryStructure estimates the directory structure section of the
+// files still included in the summary
+func (m *Model) updateSummaryStructure() {
+	excluded := make(map[string]bool)
+	for _, path := range m.summary.Excluded() {
+		excluded[path] = true
+	}
+	var items []ui.FileItem
+	for _, item := range m.selectedForOutput() {
+		if !excluded[item.Path] {
+			items = append(items, item)
+		}
+	}
+	m.summary.SetStructure(m.config.IncludeStructure, tokens.Estimate(structureSection(items, m.cwd, m.config)))
+}
+
+// updateSelectionSummary handles a key press while the selection summary is
+// open. Files left out in the summary are deselected once it's accepted.
+func (m *Model) updateSelectionSummary(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "q":
+		m.showSummary = false
+		return nil
+
+	case " ", "tab":
+		m.summary.ToggleExcluded()
+		m.updateSummaryStructure()
+		return nil
+
+	case "s": // Include the directory structure section, or leave it out
+		m.config.IncludeStructure = !m.config.IncludeStructure
+		m.updateSummaryStructure()
+		return nil
+
+	case "enter":
+		if m.summary.Remaining() == 0 {
+			m.setStatusMessage("Every file is left out, nothing to copy", 2)
+			return nil
+		}
+		m.showSummary = false
+		for _, path := range m.summary.Excluded() {
+			m.toggleSelection(path, false)
+		}
+		if m.summaryConfirms {
+			return m.confirmSelection()
+		}
+		m.copyAndStay()
+		return nil
+	}
+
+	summary, cmd := m.summary.Update(msg)
+	m.summary = summary
+	return cmd
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/doganarif/llmdog/internal/tokens"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long Watch waits after the last change before
+// rewriting the output, so a burst of saves causes a single refresh
+const watchDebounce = 300 * time.Millisecond
+
+// Watch rewrites the output file whenever one of the files in the last output
+// changes, until ctx is cancelled. A line is written to log on each refresh.
+func (m *Model) Watch(ctx context.Context, outputPath string, log io.Writer) error {
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, item := range m.lastItems {
+		if item.IsDir {
+			continue
+		}
+		files[filepath.Clean(item.Path)] = true
+		// Watch the parent directory, since editors often save by
+		// replacing the file, which would drop a watch on the file itself
+		dirs[filepath.Dir(item.Path)] = true
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files to watch")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("could not watch %s: %w", dir, err)
+		}
+	}
+
+	fmt.Fprintf(log, "Watching %d files, writing to %s (Ctrl+C to stop)\n", len(files), outputPath)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !files[filepath.Clean(event.Name)] || event.Op == fsnotify.Chmod {
+				continue
+			}
+			slog.Debug("watched file changed", "path", event.Name, "op", event.Op.String())
+			debounce = time.After(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("file watcher error", "err", err)
+
+		case <-debounce:
+			debounce = nil
+			m.lastStats = outputStats{}
+			output := buildOutput(m.lastItems, m.cwd, m.config, &m.lastStats)
+			if err := os.WriteFile(outputPath, []byte(output), 0644); err != nil {
+				fmt.Fprintf(log, "Could not write %s: %v\n", outputPath, err)
+				continue
+			}
+			m.lastOutput = output
+			fmt.Fprintf(log, "[%s] Refreshed %s (~%d tokens)\n",
+				time.Now().Format("15:04:05"), outputPath, tokens.Estimate(output))
+		}
+	}
+}
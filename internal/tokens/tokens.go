@@ -0,0 +1,35 @@
+package tokens
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CharsPerToken is the average number of characters per token assumed by the estimates
+const CharsPerToken = 4
+
+// Estimate returns a rough token count for a piece of text
+func Estimate(text string) int {
+	return len(text) / CharsPerToken
+}
+
+// EstimateSize returns a rough token count for a file of the given size in bytes
+func EstimateSize(size int64) int {
+	return int(size) / CharsPerToken
+}
+
+// Costs estimates what count input tokens cost with each model, given prices
+// in USD per million input tokens, as "model ~$0.0123" sorted by model name
+func Costs(count int, prices map[string]float64) []string {
+	models := make([]string, 0, len(prices))
+	for model := range prices {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	costs := make([]string, 0, len(models))
+	for _, model := range models {
+		costs = append(costs, fmt.Sprintf("%s ~$%.4f", model, float64(count)*prices[model]/1_000_000))
+	}
+	return costs
+}
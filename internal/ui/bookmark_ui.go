@@ -1,24 +1,64 @@
 package ui
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/doganarif/llmdog/internal/bookmarks"
+	"github.com/doganarif/llmdog/internal/tokens"
 )
 
 // BookmarkItem represents a bookmark in the UI list
 type BookmarkItem struct {
-	Name     string
-	DescText string
+	Name      string
+	DescText  string
+	FileCount int
+	Size      int64
+	Tokens    int
+	Created   time.Time
+	Modified  time.Time
+	Marked    bool // Marked for merging
 }
 
 // Implement list.Item interface
-func (b BookmarkItem) Title() string       { return b.Name }
-func (b BookmarkItem) Description() string { return b.DescText }
+func (b BookmarkItem) Title() string {
+	if b.Marked {
+		return "✓ " + b.Name
+	}
+	return b.Name
+}
+
 func (b BookmarkItem) FilterValue() string { return b.Name }
 
+func (b BookmarkItem) Description() string {
+	parts := []string{}
+	if b.DescText != "" {
+		parts = append(parts, b.DescText)
+	}
+
+	if b.FileCount == 1 {
+		parts = append(parts, "1 file")
+	} else {
+		parts = append(parts, fmt.Sprintf("%d files", b.FileCount))
+	}
+	parts = append(parts, fmt.Sprintf("~%d tokens (%s)", b.Tokens, formatSize(b.Size)))
+
+	parts = append(parts, "created "+b.Created.Format("2006-01-02"))
+	if b.Modified.Format("2006-01-02") != b.Created.Format("2006-01-02") {
+		parts = append(parts, "modified "+b.Modified.Format("2006-01-02"))
+	}
+
+	return strings.Join(parts, " • ")
+}
+
 // BookmarksMenu is the UI component for bookmark management
 type BookmarksMenu struct {
 	list   list.Model
@@ -30,14 +70,20 @@ type BookmarksMenu struct {
 func NewBookmarksMenu(bookmarks []bookmarks.Bookmark, width, height int) BookmarksMenu {
 	var items []list.Item
 	for _, b := range bookmarks {
+		stats := getBookmarkStats(b)
 		items = append(items, BookmarkItem{
-			Name:     b.Name,
-			DescText: b.Description,
+			Name:      b.Name,
+			DescText:  b.Description,
+			FileCount: len(b.FilePaths),
+			Size:      stats.size,
+			Tokens:    stats.tokens,
+			Created:   b.Created,
+			Modified:  b.Modified,
 		})
 	}
 
 	l := list.New(items, list.NewDefaultDelegate(), width, height)
-	l.Title = " Bookmarks  |  Enter:Apply  •  n:New  •  d:Delete  •  r:Rename  •  Esc:Close "
+	l.Title = " Bookmarks  |  Enter:Apply  •  a:Append  •  n:New  •  d:Delete  •  r:Rename  •  Space:Mark  •  m:Merge  •  Esc:Close "
 
 	return BookmarksMenu{
 		list:   l,
@@ -46,6 +92,46 @@ func NewBookmarksMenu(bookmarks []bookmarks.Bookmark, width, height int) Bookmar
 	}
 }
 
+// bookmarkStats holds the total size and estimated tokens of a bookmark's files
+type bookmarkStats struct {
+	size   int64
+	tokens int
+}
+
+var bookmarkStatsCache = struct {
+	sync.RWMutex
+	cache map[string]bookmarkStats
+}{cache: make(map[string]bookmarkStats)}
+
+// getBookmarkStats computes the size of a bookmark's files, caching the result
+// until the bookmark is modified
+func getBookmarkStats(b bookmarks.Bookmark) bookmarkStats {
+	key := b.Name + "\x00" + b.Modified.String()
+
+	bookmarkStatsCache.RLock()
+	if stats, ok := bookmarkStatsCache.cache[key]; ok {
+		bookmarkStatsCache.RUnlock()
+		return stats
+	}
+	bookmarkStatsCache.RUnlock()
+
+	var stats bookmarkStats
+	for _, relPath := range b.FilePaths {
+		info, err := os.Stat(filepath.Join(b.RootPath, relPath))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		stats.size += info.Size()
+		stats.tokens += tokens.EstimateSize(info.Size())
+	}
+
+	bookmarkStatsCache.Lock()
+	bookmarkStatsCache.cache[key] = stats
+	bookmarkStatsCache.Unlock()
+
+	return stats
+}
+
 // Update handles input for the bookmarks menu
 func (b *BookmarksMenu) Update(msg tea.Msg) (BookmarksMenu, tea.Cmd) {
 	var cmd tea.Cmd
@@ -63,6 +149,27 @@ func (b *BookmarksMenu) View() string {
 		Render(b.list.View())
 }
 
+// ToggleMark marks the highlighted bookmark for merging, or unmarks it
+func (b *BookmarksMenu) ToggleMark() {
+	selected, ok := b.list.SelectedItem().(BookmarkItem)
+	if !ok {
+		return
+	}
+	selected.Marked = !selected.Marked
+	b.list.SetItem(b.list.Index(), selected)
+}
+
+// MarkedBookmarks returns the names of the marked bookmarks, in menu order
+func (b *BookmarksMenu) MarkedBookmarks() []string {
+	var names []string
+	for _, item := range b.list.Items() {
+		if bookmark, ok := item.(BookmarkItem); ok && bookmark.Marked {
+			names = append(names, bookmark.Name)
+		}
+	}
+	return names
+}
+
 // SelectedBookmark returns the currently selected bookmark
 func (b *BookmarksMenu) SelectedBookmark() (string, bool) {
 	if len(b.list.Items()) == 0 {
@@ -81,6 +188,7 @@ func (b *BookmarksMenu) SelectedBookmark() (string, bool) {
 type TextInputModal struct {
 	textInput textinput.Model
 	title     string
+	message   string
 	width     int
 }
 
@@ -97,6 +205,17 @@ func NewTextInputModal(title string, placeholder string, width int) TextInputMod
 	}
 }
 
+// SetValue prefills the input with value
+func (t *TextInputModal) SetValue(value string) {
+	t.textInput.SetValue(value)
+	t.textInput.CursorEnd()
+}
+
+// SetMessage sets an optional body shown between the title and the input
+func (t *TextInputModal) SetMessage(message string) {
+	t.message = message
+}
+
 // Update handles input for the text input
 func (t *TextInputModal) Update(msg tea.Msg) (TextInputModal, tea.Cmd) {
 	var cmd tea.Cmd
@@ -106,20 +225,19 @@ func (t *TextInputModal) Update(msg tea.Msg) (TextInputModal, tea.Cmd) {
 
 // View renders the text input modal
 func (t *TextInputModal) View() string {
+	parts := []string{EmphasisStyle.Render(t.title), ""}
+	if t.message != "" {
+		parts = append(parts, lipgloss.NewStyle().Align(lipgloss.Left).Render(t.message), "")
+	}
+	parts = append(parts, t.textInput.View(), "", "Enter: Confirm • Esc: Cancel")
+
 	return lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(lipgloss.Color("205")).
 		Padding(1, 2).
 		Width(t.width).
 		Render(
-			lipgloss.JoinVertical(
-				lipgloss.Center,
-				EmphasisStyle.Render(t.title),
-				"",
-				t.textInput.View(),
-				"",
-				"Enter: Confirm • Esc: Cancel",
-			),
+			lipgloss.JoinVertical(lipgloss.Center, parts...),
 		)
 }
 
@@ -0,0 +1,174 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// GitStatusCategory is a group of files in the git status menu, such as the
+// staged files
+type GitStatusCategory struct {
+	Name  string
+	Files []string // Absolute paths
+	Rel   []string // Paths shown in the menu, in the same order as Files
+}
+
+// GitStatusItem is a category header or one of its files in the git status menu
+type GitStatusItem struct {
+	Category string
+	Path     string // Empty for a category header
+	RelPath  string
+	Checked  bool
+	files    int // Files in the category, for headers
+	checked  int // Checked files in the category, for headers
+}
+
+// Implement list.Item interface
+func (g GitStatusItem) Title() string {
+	if g.Path == "" {
+		box := "[ ]"
+		if g.checked == g.files {
+			box = "[x]"
+		} else if g.checked > 0 {
+			box = "[-]"
+		}
+		return fmt.Sprintf("%s %s (%d)", box, g.Category, g.files)
+	}
+	if g.Checked {
+		return "    [x] " + g.RelPath
+	}
+	return "    [ ] " + g.RelPath
+}
+
+func (g GitStatusItem) FilterValue() string { return g.RelPath }
+
+func (g GitStatusItem) Description() string { return "" }
+
+// GitStatusMenu lets the user pick changed files by git status category
+type GitStatusMenu struct {
+	list   list.Model
+	width  int
+	height int
+}
+
+// NewGitStatusMenu creates a git status menu for the categories, with the
+// files in checked ticked
+func NewGitStatusMenu(categories []GitStatusCategory, checked map[string]bool, width, height int) GitStatusMenu {
+	var items []list.Item
+	for _, category := range categories {
+		if len(category.Files) == 0 {
+			continue
+		}
+		items = append(items, GitStatusItem{Category: category.Name})
+		for i, path := range category.Files {
+			items = append(items, GitStatusItem{
+				Category: category.Name,
+				Path:     path,
+				RelPath:  category.Rel[i],
+				Checked:  checked[path],
+			})
+		}
+	}
+
+	delegate := list.NewDefaultDelegate()
+	delegate.ShowDescription = false
+	delegate.SetSpacing(0)
+
+	l := list.New(items, delegate, width, height)
+	l.Title = " Git Status "
+	l.SetShowHelp(false)
+	l.SetFilteringEnabled(false)
+	l.SetShowStatusBar(false)
+
+	g := GitStatusMenu{
+		list:   l,
+		width:  width,
+		height: height,
+	}
+	g.updateHeaders()
+	return g
+}
+
+// updateHeaders refreshes the counts shown on the category headers
+func (g *GitStatusMenu) updateHeaders() {
+	files := make(map[string]int)
+	checked := make(map[string]int)
+	for _, item := range g.list.Items() {
+		if file, ok := item.(GitStatusItem); ok && file.Path != "" {
+			files[file.Category]++
+			if file.Checked {
+				checked[file.Category]++
+			}
+		}
+	}
+
+	for i, item := range g.list.Items() {
+		if header, ok := item.(GitStatusItem); ok && header.Path == "" {
+			header.files, header.checked = files[header.Category], checked[header.Category]
+			g.list.SetItem(i, header)
+		}
+	}
+}
+
+// Toggle ticks the highlighted file, or every file of the highlighted
+// category, or unticks them when they all are. A file listed in several
+// categories is ticked in all of them.
+func (g *GitStatusMenu) Toggle() {
+	selected, ok := g.list.SelectedItem().(GitStatusItem)
+	if !ok {
+		return
+	}
+
+	changed := make(map[string]bool)
+	if selected.Path == "" {
+		check := selected.checked < selected.files
+		for _, item := range g.list.Items() {
+			if file, ok := item.(GitStatusItem); ok && file.Path != "" && file.Category == selected.Category {
+				changed[file.Path] = check
+			}
+		}
+	} else {
+		changed[selected.Path] = !selected.Checked
+	}
+
+	for i, item := range g.list.Items() {
+		if file, ok := item.(GitStatusItem); ok && file.Path != "" {
+			if check, found := changed[file.Path]; found {
+				file.Checked = check
+				g.list.SetItem(i, file)
+			}
+		}
+	}
+	g.updateHeaders()
+}
+
+// Checked reports for every file in the menu whether it is ticked
+func (g *GitStatusMenu) Checked() map[string]bool {
+	checked := make(map[string]bool)
+	for _, item := range g.list.Items() {
+		if file, ok := item.(GitStatusItem); ok && file.Path != "" {
+			checked[file.Path] = file.Checked
+		}
+	}
+	return checked
+}
+
+// Update handles input for the git status menu
+func (g *GitStatusMenu) Update(msg tea.Msg) (GitStatusMenu, tea.Cmd) {
+	var cmd tea.Cmd
+	g.list, cmd = g.list.Update(msg)
+	return *g, cmd
+}
+
+// View renders the git status menu
+func (g *GitStatusMenu) View() string {
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2).
+		Width(g.width).
+		Render(g.list.View())
+}
@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// RecentItem is a recently browsed directory in the --recent picker
+type RecentItem struct {
+	Dir      string
+	Selected int
+	Used     time.Time
+}
+
+// Implement list.Item interface
+func (r RecentItem) Title() string {
+	// Shorten paths in the home directory the way shells show them
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(r.Dir, home+string(filepath.Separator)) {
+		return "~" + strings.TrimPrefix(r.Dir, home)
+	}
+	return r.Dir
+}
+
+func (r RecentItem) FilterValue() string { return r.Dir }
+
+func (r RecentItem) Description() string {
+	files := fmt.Sprintf("%d files selected", r.Selected)
+	if r.Selected == 1 {
+		files = "1 file selected"
+	}
+	return fmt.Sprintf("%s • last used %s", files, r.Used.Format("2006-01-02 15:04"))
+}
+
+// RecentPicker lets the user choose a recent directory to open
+type RecentPicker struct {
+	list   list.Model
+	choice string
+}
+
+// NewRecentPicker creates a picker for the recent directories
+func NewRecentPicker(items []RecentItem) *RecentPicker {
+	var listItems []list.Item
+	for _, item := range items {
+		listItems = append(listItems, item)
+	}
+
+	l := list.New(listItems, list.NewDefaultDelegate(), 0, 0)
+	l.Title = " Recent directories  |  Enter:Open  •  Esc:Cancel "
+	l.SetStatusBarItemName("directory", "directories")
+
+	return &RecentPicker{list: l}
+}
+
+// Init initializes the picker
+func (r *RecentPicker) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles input for the picker
+func (r *RecentPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.list.SetSize(msg.Width-2, msg.Height-2)
+		return r, nil
+
+	case tea.KeyMsg:
+		// Keys typed into the filter belong to the filter
+		if r.list.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.String() {
+		case "enter":
+			if item, ok := r.list.SelectedItem().(RecentItem); ok {
+				r.choice = item.Dir
+			}
+			return r, tea.Quit
+		case "esc":
+			// The first Esc clears an applied filter
+			if r.list.FilterState() == list.FilterApplied {
+				break
+			}
+			return r, tea.Quit
+		case "q", "ctrl+c":
+			return r, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	r.list, cmd = r.list.Update(msg)
+	return r, cmd
+}
+
+// View renders the picker
+func (r *RecentPicker) View() string {
+	return lipgloss.NewStyle().Margin(1, 1).Render(r.list.View())
+}
+
+// Choice returns the chosen directory, or "" when the picker was cancelled
+func (r *RecentPicker) Choice() string {
+	return r.choice
+}
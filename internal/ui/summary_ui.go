@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/doganarif/llmdog/internal/tokens"
+)
+
+// SummaryItem is a file in the selection summary shown before copying
+type SummaryItem struct {
+	Path     string
+	RelPath  string
+	Size     int64
+	Tokens   int
+	Excluded bool // Left out of the copy from within the summary
+}
+
+// Implement list.Item interface
+func (s SummaryItem) Title() string {
+	if s.Excluded {
+		return "[ ] " + s.RelPath
+	}
+	return "[x] " + s.RelPath
+}
+
+func (s SummaryItem) FilterValue() string { return s.RelPath }
+
+func (s SummaryItem) Description() string {
+	return fmt.Sprintf("~%d tokens (%s)", s.Tokens, formatSize(s.Size))
+}
+
+// SelectionSummary lists the files about to be copied with their sizes, so
+// the selection can be trimmed at the last moment
+type SelectionSummary struct {
+	list             list.Model
+	width            int
+	height           int
+	includeStructure bool
+	structureTokens  int // Estimated tokens of the directory structure section
+	tokens           int // Estimated tokens of everything that will be copied
+	prices           map[string]float64
+}
+
+// NewSelectionSummary creates a selection summary, listing the largest files first
+func NewSelectionSummary(files []SummaryItem, width, height int) SelectionSummary {
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Tokens > files[j].Tokens
+	})
+
+	var items []list.Item
+	for _, file := range files {
+		items = append(items, file)
+	}
+
+	l := list.New(items, list.NewDefaultDelegate(), width, height)
+	l.SetShowHelp(false)
+	l.SetFilteringEnabled(false)
+	l.SetStatusBarItemName("file", "files")
+	s := SelectionSummary{
+		list:   l,
+		width:  width,
+		height: height,
+	}
+	s.updateTitle()
+	return s
+}
+
+// updateTitle shows the totals of the files that will be copied
+func (s *SelectionSummary) updateTitle() {
+	count, size, tokens := 0, int64(0), 0
+	for _, item := range s.list.Items() {
+		if file, ok := item.(SummaryItem); ok && !file.Excluded {
+			count++
+			size += file.Size
+			tokens += file.Tokens
+		}
+	}
+	s.tokens = tokens
+	if s.includeStructure {
+		s.tokens += s.structureTokens
+		s.list.Title = fmt.Sprintf(" Copy %d files, ~%d tokens (%s), structure ~%d tokens ",
+			count, tokens+s.structureTokens, formatSize(size), s.structureTokens)
+	} else {
+		s.list.Title = fmt.Sprintf(" Copy %d files, ~%d tokens (%s), no structure ", count, tokens, formatSize(size))
+	}
+}
+
+// SetStructure sets whether the directory structure section is copied and its
+// estimated size, which counts towards the total when it is
+func (s *SelectionSummary) SetStructure(included bool, tokens int) {
+	s.includeStructure = included
+	s.structureTokens = tokens
+	s.updateTitle()
+}
+
+// SetPrices sets the prices per million input tokens of the models whose cost
+// is estimated below the list
+func (s *SelectionSummary) SetPrices(prices map[string]float64) {
+	s.prices = prices
+}
+
+// Update handles input for the selection summary
+func (s *SelectionSummary) Update(msg tea.Msg) (SelectionSummary, tea.Cmd) {
+	var cmd tea.Cmd
+	s.list, cmd = s.list.Update(msg)
+	return *s, cmd
+}
+
+// View renders the selection summary
+func (s *SelectionSummary) View() string {
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(1, 2).
+		Width(s.width).
+		Render(s.list.View() + s.costsView())
+}
+
+// costsView estimates the cost of the copy with each model that has a price
+func (s *SelectionSummary) costsView() string {
+	if len(s.prices) == 0 {
+		return ""
+	}
+	return "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("240")).
+		Width(s.width-4).
+		Render("Cost: "+strings.Join(tokens.Costs(s.tokens, s.prices), " • "))
+}
+
+// ToggleExcluded leaves the highlighted file out of the copy, or puts it back
+func (s *SelectionSummary) ToggleExcluded() {
+	selected, ok := s.list.SelectedItem().(SummaryItem)
+	if !ok {
+		return
+	}
+	selected.Excluded = !selected.Excluded
+	s.list.SetItem(s.list.Index(), selected)
+	s.updateTitle()
+}
+
+// Excluded returns the paths of the files left out of the copy
+func (s *SelectionSummary) Excluded() []string {
+	var paths []string
+	for _, item := range s.list.Items() {
+		if file, ok := item.(SummaryItem); ok && file.Excluded {
+			paths = append(paths, file.Path)
+		}
+	}
+	return paths
+}
+
+// Remaining returns how many files are still included in the copy
+func (s *SelectionSummary) Remaining() int {
+	return len(s.list.Items()) - len(s.Excluded())
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -8,10 +9,14 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/doganarif/llmdog/internal/tokens"
 )
 
 var (
@@ -64,15 +69,20 @@ var (
 
 // FileItem represents a file or directory in the file system
 type FileItem struct {
-	Path           string
-	Name           string
-	IsDir          bool
-	Selected       bool
-	Depth          int
-	Expanded       bool
-	GitIgnored     bool
-	ChildrenLoaded bool
-	MatchesContent bool
+	Path              string
+	Name              string
+	IsDir             bool
+	Selected          bool
+	PartiallySelected bool // Directory with only some of its descendants selected
+	Depth             int
+	Expanded          bool
+	GitIgnored        bool
+	ChildrenLoaded    bool
+	MatchesContent    bool
+	NameMatches       []int  // Rune positions in Name matched by the current search
+	LineStart         int    // First line to output, 0 for the whole file
+	LineEnd           int    // Last line to output when LineStart is set
+	Note              string // Comment emitted above the file in the output
 }
 
 func (f FileItem) Title() string {
@@ -92,6 +102,8 @@ func (f FileItem) Title() string {
 	// Add selection checkbox
 	if f.Selected {
 		builder.WriteString("[✓] ")
+	} else if f.PartiallySelected {
+		builder.WriteString("[-] ")
 	} else {
 		builder.WriteString("[ ] ")
 	}
@@ -129,7 +141,14 @@ func (f FileItem) FilterValue() string {
 }
 
 // ItemDelegate handles the rendering of list items
-type ItemDelegate struct{}
+type ItemDelegate struct {
+	RecursiveCounts bool     // Show cached recursive file counts for directories
+	ExcludeDirs     []string // Directory names skipped when counting
+	RelativePaths   bool     // Show each item's path relative to Root instead of just its name
+	TokenBadges     bool     // Show estimated token counts, summed for directories
+	HideFileInfo    bool     // Leave out the size and entry count after each name
+	Root            string
+}
 
 func (d ItemDelegate) Height() int                               { return 1 }
 func (d ItemDelegate) Spacing() int                              { return 0 }
@@ -140,6 +159,23 @@ func (d ItemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 		return
 	}
 
+	// Highlight the characters that matched the filter, preferring the list's
+	// own fuzzy matches and falling back to our search's substring matches
+	matches := m.MatchesForItem(index)
+	if len(matches) == 0 {
+		matches = i.NameMatches
+	}
+
+	// Rows without highlights are formatted once until something they show changes
+	showInfo := !d.HideFileInfo && m.FilterState() != list.Filtering
+	key := newRowKey(d, i, index == m.Index(), showInfo, m.Width())
+	if len(matches) == 0 {
+		if row, ok := cachedRow(key); ok {
+			fmt.Fprint(w, row)
+			return
+		}
+	}
+
 	// Base style with indentation
 	style := lipgloss.NewStyle().PaddingLeft(i.Depth * 2)
 
@@ -167,6 +203,8 @@ func (d ItemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 	// Add selection indicator with more visible checkboxes
 	if i.Selected {
 		builder.WriteString("✅ ")
+	} else if i.PartiallySelected {
+		builder.WriteString("◐  ")
 	} else {
 		builder.WriteString("☐  ")
 	}
@@ -175,19 +213,50 @@ func (d ItemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 	icon := getFileIcon(i.Name, i.IsDir)
 	builder.WriteString(icon)
 	builder.WriteString(" ")
+	if d.RelativePaths {
+		if dir, err := filepath.Rel(d.Root, filepath.Dir(i.Path)); err == nil && dir != "." {
+			builder.WriteString(filepath.ToSlash(dir) + "/")
+		}
+	}
+	prefix := builder.String()
 	builder.WriteString(i.Name)
 
+	// Build the suffix separately so the name can be highlighted on its own
+	var suffix strings.Builder
+
 	// Add content match indicator
 	if i.MatchesContent {
-		builder.WriteString(" 🔍")
+		suffix.WriteString(" 🔍")
+	}
+
+	// Add line range indicator
+	if i.LineStart > 0 {
+		suffix.WriteString(fmt.Sprintf(" [L%d-%d]", i.LineStart, i.LineEnd))
 	}
 
-	// Add size/count info
-	info := getFileInfo(i)
+	// Add note indicator
+	if i.Note != "" {
+		suffix.WriteString(" ✎")
+	}
+
+	// Add size/count info, except while typing a filter so names have the room
+	var info string
+	if showInfo && i.IsDir && d.RecursiveCounts {
+		info = recursiveFileCountInfo(i.Path, d.ExcludeDirs)
+	} else if showInfo {
+		info = getFileInfo(i)
+	}
 	if info != "" {
-		builder.WriteString(" ")
-		builder.WriteString(info)
+		suffix.WriteString(" ")
+		suffix.WriteString(info)
 	}
+	if d.TokenBadges {
+		if badge := tokenBadge(i, d.ExcludeDirs); badge != "" {
+			suffix.WriteString(" ")
+			suffix.WriteString(badge)
+		}
+	}
+	builder.WriteString(suffix.String())
 
 	// Apply appropriate style based on item state
 	if i.GitIgnored {
@@ -206,7 +275,110 @@ func (d ItemDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 		style = style.Inherit(NormalStyle)
 	}
 
-	fmt.Fprint(w, style.Render(builder.String()))
+	if len(matches) > 0 {
+		textStyle := style.UnsetPaddingLeft()
+		name := lipgloss.StyleRunes(i.Name, matches, HighlightStyle.Underline(true).Inherit(textStyle), textStyle)
+		line := textStyle.Render(prefix) + name + textStyle.Render(suffix.String())
+		fmt.Fprint(w, lipgloss.NewStyle().PaddingLeft(i.Depth*2).Render(line))
+		return
+	}
+
+	row := style.Render(builder.String())
+	storeRow(key, row)
+	fmt.Fprint(w, row)
+}
+
+// rowKey is everything a rendered list row depends on, so toggling, expanding,
+// moving the cursor or resizing renders the row afresh
+type rowKey struct {
+	path, name                                  string
+	depth, lineStart, lineEnd, width            int
+	isDir, expanded, selected, partial, ignored bool
+	matchesContent, hasNote, cursor, showInfo   bool
+	recursiveCounts, relativePaths, tokenBadges bool
+	root                                        string
+}
+
+// newRowKey builds the key of item's row as d renders it
+func newRowKey(d ItemDelegate, item FileItem, cursor, showInfo bool, width int) rowKey {
+	return rowKey{
+		path:            item.Path,
+		name:            item.Name,
+		depth:           item.Depth,
+		lineStart:       item.LineStart,
+		lineEnd:         item.LineEnd,
+		width:           width,
+		isDir:           item.IsDir,
+		expanded:        item.Expanded,
+		selected:        item.Selected,
+		partial:         item.PartiallySelected,
+		ignored:         item.GitIgnored,
+		matchesContent:  item.MatchesContent,
+		hasNote:         item.Note != "",
+		cursor:          cursor,
+		showInfo:        showInfo,
+		recursiveCounts: d.RecursiveCounts,
+		relativePaths:   d.RelativePaths,
+		tokenBadges:     d.TokenBadges,
+		root:            d.Root,
+	}
+}
+
+// maxCachedRows bounds the row cache, since every state a row has been in gets
+// its own entry
+const maxCachedRows = 10000
+
+// rowCache holds the rendered text of list rows, so scrolling and redrawing
+// don't format the name, badges and sizes of every visible row on every frame
+var rowCache = struct {
+	sync.RWMutex
+	cache map[rowKey]string
+}{cache: make(map[rowKey]string)}
+
+// cachedRow returns the rendered row for key, if there is one
+func cachedRow(key rowKey) (string, bool) {
+	rowCache.RLock()
+	defer rowCache.RUnlock()
+	row, ok := rowCache.cache[key]
+	return row, ok
+}
+
+// storeRow remembers the rendered row for key, starting over when the cache is full
+func storeRow(key rowKey, row string) {
+	rowCache.Lock()
+	defer rowCache.Unlock()
+	if len(rowCache.cache) >= maxCachedRows {
+		rowCache.cache = make(map[rowKey]string)
+	}
+	rowCache.cache[key] = row
+}
+
+// clearRowCache drops all rendered rows
+func clearRowCache() {
+	rowCache.Lock()
+	rowCache.cache = make(map[rowKey]string)
+	rowCache.Unlock()
+}
+
+// SubstringMatches returns the rune positions in name covered by the first
+// case-insensitive occurrence of query, or nil if it doesn't occur
+func SubstringMatches(name, query string) []int {
+	nameRunes := []rune(strings.ToLower(name))
+	queryRunes := []rune(strings.ToLower(query))
+	if len(queryRunes) == 0 || len(queryRunes) > len(nameRunes) {
+		return nil
+	}
+
+	for start := 0; start+len(queryRunes) <= len(nameRunes); start++ {
+		if string(nameRunes[start:start+len(queryRunes)]) == string(queryRunes) {
+			matches := make([]int, len(queryRunes))
+			for j := range matches {
+				matches[j] = start + j
+			}
+			return matches
+		}
+	}
+	return nil
 }
 
 func getFileIcon(name string, isDir bool) string {
@@ -267,25 +439,78 @@ func getFileIcon(name string, isDir bool) string {
 	}
 }
 
+// fileInfo is what the list shows about a file or directory: its size, or
+// the number of entries in a directory
+type fileInfo struct {
+	label string
+	size  int64
+	ok    bool // The item could be read
+}
+
+// fileInfoCache holds the info of every rendered item, so scrolling doesn't
+// stat each visible row on every frame
+var fileInfoCache = struct {
+	sync.RWMutex
+	cache map[string]fileInfo
+}{cache: make(map[string]fileInfo)}
+
+// ClearFileInfoCache drops all cached file sizes and directory entry counts,
+// along with the rendered rows showing them
+func ClearFileInfoCache() {
+	fileInfoCache.Lock()
+	fileInfoCache.cache = make(map[string]fileInfo)
+	fileInfoCache.Unlock()
+	clearRowCache()
+}
+
+// cachedFileInfo returns the info of item, reading it only the first time
+func cachedFileInfo(item FileItem) fileInfo {
+	fileInfoCache.RLock()
+	info, ok := fileInfoCache.cache[item.Path]
+	fileInfoCache.RUnlock()
+	if ok {
+		return info
+	}
+
+	info = statFileInfo(item)
+	fileInfoCache.Lock()
+	fileInfoCache.cache[item.Path] = info
+	fileInfoCache.Unlock()
+	return info
+}
+
 func getFileInfo(item FileItem) string {
+	return cachedFileInfo(item).label
+}
+
+// statFileInfo reads the info of item from the file system
+func statFileInfo(item FileItem) fileInfo {
 	info, err := os.Stat(item.Path)
 	if err != nil {
-		return ""
+		return fileInfo{}
 	}
 
 	if item.IsDir {
 		entries, err := os.ReadDir(item.Path)
 		if err != nil {
-			return ""
+			return fileInfo{}
 		}
 		count := len(entries)
+		if count == 0 {
+			return fileInfo{label: "(empty)", ok: true}
+		}
 		if count == 1 {
-			return "(1 item)"
+			return fileInfo{label: "(1 item)", ok: true}
 		}
-		return fmt.Sprintf("(%d items)", count)
+		return fileInfo{label: fmt.Sprintf("(%d items)", count), ok: true}
 	}
 
 	size := info.Size()
+	return fileInfo{label: sizeLabel(size), size: size, ok: true}
+}
+
+// sizeLabel describes a file size for the list
+func sizeLabel(size int64) string {
 	switch {
 	case size == 0:
 		return "(empty)"
@@ -300,23 +525,212 @@ func getFileInfo(item FileItem) string {
 	}
 }
 
-// LoadFiles walks through the directory tree and returns a slice of FileItems
-func LoadFiles(root string, gitRegex *regexp.Regexp, showHidden bool) []FileItem {
+// dirStats is the number and total size of the files below a directory
+type dirStats struct {
+	files int
+	size  int64
+}
+
+// dirCountCache holds recursive file counts so they are computed once per directory
+var dirCountCache = struct {
+	sync.RWMutex
+	cache map[string]dirStats
+}{cache: make(map[string]dirStats)}
+
+// ClearDirCountCache drops all cached directory file counts, along with the
+// rendered rows showing them
+func ClearDirCountCache() {
+	dirCountCache.Lock()
+	dirCountCache.cache = make(map[string]dirStats)
+	dirCountCache.Unlock()
+	clearRowCache()
+}
+
+// recursiveDirStats counts the files below a directory and their total size,
+// walking it only the first time it is asked for
+func recursiveDirStats(path string, excludeDirs []string) dirStats {
+	dirCountCache.RLock()
+	stats, ok := dirCountCache.cache[path]
+	dirCountCache.RUnlock()
+	if ok {
+		return stats
+	}
+
+	filepath.WalkDir(path, func(p string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() && p != path && IsExcludedDir(entry.Name(), excludeDirs) {
+			return filepath.SkipDir
+		}
+		if !entry.IsDir() {
+			stats.files++
+			if info, err := entry.Info(); err == nil {
+				stats.size += info.Size()
+			}
+		}
+		return nil
+	})
+
+	dirCountCache.Lock()
+	dirCountCache.cache[path] = stats
+	dirCountCache.Unlock()
+	return stats
+}
+
+// recursiveFileCountInfo describes the number of files below a directory
+func recursiveFileCountInfo(path string, excludeDirs []string) string {
+	switch count := recursiveDirStats(path, excludeDirs).files; count {
+	case 0:
+		return "(empty)"
+	case 1:
+		return "(1 file)"
+	default:
+		return fmt.Sprintf("(%d files)", count)
+	}
+}
+
+// tokenBadge describes the estimated token count of a file, or of all the
+// files below a directory
+func tokenBadge(item FileItem, excludeDirs []string) string {
+	var size int64
+	if item.IsDir {
+		size = recursiveDirStats(item.Path, excludeDirs).size
+	} else if info := cachedFileInfo(item); info.ok {
+		size = info.size
+	} else {
+		return ""
+	}
+
+	count := tokens.EstimateSize(size)
+	if count < 1000 {
+		return fmt.Sprintf("~%d tok", count)
+	}
+	return fmt.Sprintf("~%.1fk tok", float64(count)/1000)
+}
+
+// LoadOptions controls which entries are loaded into the file tree
+type LoadOptions struct {
+	GitRegex    *regexp.Regexp
+	ShowHidden  bool
+	ExcludeDirs []string // Directory names that are never traversed
+	// File extensions to show, such as "go" or ".go". Empty shows every file.
+	IncludeExtensions []string
+	// Paths inside .git to show, such as "HEAD" or "refs/heads", even though
+	// .git is otherwise hidden. Empty leaves .git to the usual rules.
+	GitMetadata []string
+}
+
+// GitMetadataPath reports whether path is inside a .git directory while git
+// metadata entries are shown and, if so, whether it is one of those entries,
+// below one, or a directory leading to one
+func GitMetadataPath(path string, entries []string) (inGit, shown bool) {
+	if len(entries) == 0 {
+		return false, false
+	}
+
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i, part := range parts {
+		if part != ".git" {
+			continue
+		}
+		rel := strings.Join(parts[i+1:], "/")
+		if rel == "" {
+			return true, true
+		}
+		for _, entry := range entries {
+			entry = strings.Trim(filepath.ToSlash(entry), "/")
+			if rel == entry || strings.HasPrefix(rel, entry+"/") || strings.HasPrefix(entry, rel+"/") {
+				return true, true
+			}
+		}
+		return true, false
+	}
+	return false, false
+}
+
+// IsIncludedFile checks if a file name has one of the whitelisted extensions.
+// An empty whitelist includes every file.
+func IsIncludedFile(name string, extensions []string) bool {
+	if len(extensions) == 0 {
+		return true
+	}
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	for _, included := range extensions {
+		if strings.EqualFold(ext, strings.TrimPrefix(included, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExcludedDir checks if a directory name is in the exclusion list
+func IsExcludedDir(name string, excludeDirs []string) bool {
+	for _, excluded := range excludeDirs {
+		if name == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadFiles walks through the directory tree and returns a slice of FileItems,
+// along with the paths skipped because they couldn't be read
+func LoadFiles(root string, opts LoadOptions) ([]FileItem, []string) {
 	var items []FileItem
+	var skipped []string
+	gitRegex := opts.GitRegex
+
+	// A single file root yields just that file
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		return []FileItem{{
+			Path:       root,
+			Name:       info.Name(),
+			Depth:      0,
+			GitIgnored: gitRegex != nil && gitRegex.MatchString(root),
+		}}, nil
+	}
 
 	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		// Skip unreadable subtrees, remembering them for the caller
+		if err != nil && os.IsPermission(err) {
+			skipped = append(skipped, path)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if err != nil || path == root {
 			return nil
 		}
 
+		// Only the requested git metadata is shown from .git, whatever the other rules say
+		inGit, shown := GitMetadataPath(path, opts.GitMetadata)
+		if inGit && !shown {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// Skip hidden files if not enabled
-		if !showHidden && isHiddenFile(info.Name()) {
+		if !inGit && !opts.ShowHidden && isHiddenFile(info.Name()) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
+		// Skip excluded directories entirely
+		if !inGit && info.IsDir() && IsExcludedDir(info.Name(), opts.ExcludeDirs) {
+			return filepath.SkipDir
+		}
+
+		// Hide files outside the extension whitelist
+		if !inGit && !info.IsDir() && !IsIncludedFile(info.Name(), opts.IncludeExtensions) {
+			return nil
+		}
+
 		// Calculate relative path and depth
 		rel, _ := filepath.Rel(root, path)
 		depth := len(strings.Split(rel, string(os.PathSeparator))) - 1
@@ -345,7 +759,7 @@ func LoadFiles(root string, gitRegex *regexp.Regexp, showHidden bool) []FileItem
 		return nil
 	})
 
-	return items
+	return items, skipped
 }
 
 // isHiddenFile checks if a file is hidden
@@ -354,8 +768,9 @@ func isHiddenFile(name string) bool {
 }
 
 // LoadDirectoryChildren loads only the direct children of a directory
-func LoadDirectoryChildren(dirPath string, gitRegex *regexp.Regexp, showHidden bool) ([]FileItem, error) {
+func LoadDirectoryChildren(dirPath string, opts LoadOptions) ([]FileItem, error) {
 	var items []FileItem
+	gitRegex := opts.GitRegex
 
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
@@ -376,8 +791,24 @@ func LoadDirectoryChildren(dirPath string, gitRegex *regexp.Regexp, showHidden b
 		name := entry.Name()
 		path := filepath.Join(dirPath, name)
 
+		// Only the requested git metadata is shown from .git, whatever the other rules say
+		inGit, shown := GitMetadataPath(path, opts.GitMetadata)
+		if inGit && !shown {
+			continue
+		}
+
 		// Skip hidden files if not enabled
-		if !showHidden && isHiddenFile(name) {
+		if !inGit && !opts.ShowHidden && isHiddenFile(name) {
+			continue
+		}
+
+		// Skip excluded directories entirely
+		if !inGit && entry.IsDir() && IsExcludedDir(name, opts.ExcludeDirs) {
+			continue
+		}
+
+		// Hide files outside the extension whitelist
+		if !inGit && !entry.IsDir() && !IsIncludedFile(name, opts.IncludeExtensions) {
 			continue
 		}
 
@@ -408,11 +839,27 @@ func LoadDirectoryChildren(dirPath string, gitRegex *regexp.Regexp, showHidden b
 }
 
 // LoadPreview generates a preview of the file or directory content
-func LoadPreview(path string, isDir bool, maxSize int) string {
+func LoadPreview(path string, isDir bool, maxSize, maxLines int) string {
 	if isDir {
 		return loadDirectoryPreview(path)
 	}
-	return loadFilePreview(path, maxSize)
+	return loadFilePreview(path, maxSize, maxLines)
+}
+
+// LoadInfoPreview describes a file from its metadata alone, for safe mode,
+// where file contents are only read to build the output
+func LoadInfoPreview(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Sprintf("Error getting file info: %v", err)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("File: %s\n", path))
+	builder.WriteString(fmt.Sprintf("Size: %s (~%d tokens)\n", formatSize(info.Size()), tokens.EstimateSize(info.Size())))
+	builder.WriteString(fmt.Sprintf("Modified: %s\n\n", info.ModTime().Format("2006-01-02 15:04:05")))
+	builder.WriteString("Content hidden in safe mode\n")
+	return builder.String()
 }
 
 func loadDirectoryPreview(path string) string {
@@ -471,7 +918,98 @@ var previewCache = struct {
 	cache map[string]string
 }{cache: make(map[string]string)}
 
-func loadFilePreview(path string, maxSize int) string {
+// ClearPreviewCache discards all cached file previews so they are re-read from disk
+func ClearPreviewCache() {
+	previewCache.Lock()
+	previewCache.cache = make(map[string]string)
+	previewCache.Unlock()
+
+	renderedPreviewCache.Lock()
+	renderedPreviewCache.cache = make(map[string]string)
+	renderedPreviewCache.Unlock()
+}
+
+// renderedPreviewCache holds rendered markdown previews, keyed by path and width
+var renderedPreviewCache = struct {
+	sync.RWMutex
+	cache map[string]string
+}{cache: make(map[string]string)}
+
+// IsMarkdownFile reports whether path is a markdown file
+func IsMarkdownFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// LoadMarkdownPreview renders a markdown file for the preview pane, wrapped to
+// width. Anything else gets the regular preview.
+func LoadMarkdownPreview(path string, maxSize, maxLines, width int) string {
+	if !IsMarkdownFile(path) {
+		return loadFilePreview(path, maxSize, maxLines)
+	}
+
+	key := fmt.Sprintf("%s@%d", path, width)
+	renderedPreviewCache.RLock()
+	if preview, ok := renderedPreviewCache.cache[key]; ok {
+		renderedPreviewCache.RUnlock()
+		return preview
+	}
+	renderedPreviewCache.RUnlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Sprintf("Error getting file info: %v", err)
+	}
+	if !info.Mode().IsRegular() {
+		return loadFilePreview(path, maxSize, maxLines)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Sprintf("Error reading file: %v", err)
+	}
+	if maxSize > 0 && len(data) > maxSize {
+		data = data[:maxSize]
+	}
+
+	// A fixed style avoids querying the terminal while the TUI owns it
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle("dark"),
+		glamour.WithWordWrap(width),
+	)
+	if err != nil {
+		return loadFilePreview(path, maxSize, maxLines)
+	}
+	rendered, err := renderer.Render(string(data))
+	if err != nil {
+		return loadFilePreview(path, maxSize, maxLines)
+	}
+
+	// Truncate like the raw preview does
+	lines := strings.Split(strings.Trim(rendered, "\n"), "\n")
+	if maxLines <= 0 {
+		maxLines = 50 // Default
+	}
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], "... (content truncated)")
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("File: %s\n", path))
+	builder.WriteString(fmt.Sprintf("Size: %s\n", formatSize(info.Size())))
+	builder.WriteString(fmt.Sprintf("Modified: %s\n\n", info.ModTime().Format("2006-01-02 15:04:05")))
+	builder.WriteString("Rendered Markdown:\n")
+	builder.WriteString(strings.Join(lines, "\n"))
+
+	result := builder.String()
+	renderedPreviewCache.Lock()
+	renderedPreviewCache.cache[key] = result
+	renderedPreviewCache.Unlock()
+
+	return result
+}
+
+func loadFilePreview(path string, maxSize, maxLines int) string {
 	// Check cache first
 	previewCache.RLock()
 	if preview, ok := previewCache.cache[path]; ok {
@@ -480,6 +1018,11 @@ func loadFilePreview(path string, maxSize int) string {
 	}
 	previewCache.RUnlock()
 
+	// Opening a fifo or device can block, so only regular files are read
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		return fmt.Sprintf("File: %s\n\nNot a regular file, preview unavailable\n", path)
+	}
+
 	file, err := os.Open(path)
 	if err != nil {
 		return fmt.Sprintf("Error reading file: %v", err)
@@ -516,12 +1059,35 @@ func loadFilePreview(path string, maxSize int) string {
 		return fmt.Sprintf("Error reading file content: %v", err)
 	}
 
+	// Decode the content, dropping a rune cut in half by the size limit
+	chunk := data[:n]
+	if n == maxSize {
+		for cut := 1; cut <= utf8.UTFMax && cut <= n; cut++ {
+			if utf8.RuneStart(chunk[n-cut]) {
+				if !utf8.FullRune(chunk[n-cut:]) {
+					chunk = chunk[:n-cut]
+				}
+				break
+			}
+		}
+	}
+	decoded, encoding, ok := DecodeText(chunk)
+	if !ok {
+		builder.WriteString("Warning: content is not valid UTF-8 and will be skipped in the output\n")
+		return builder.String()
+	}
+	if encoding != EncodingUTF8 {
+		builder.WriteString(fmt.Sprintf("Encoding: %s (converted to UTF-8 in the output)\n\n", encoding))
+	}
+
 	// Process content
-	content := string(data[:n])
+	content := string(decoded)
 	lines := strings.Split(content, "\n")
 
 	// Truncate if too many lines
-	maxLines := 50
+	if maxLines <= 0 {
+		maxLines = 50 // Default
+	}
 	if len(lines) > maxLines {
 		lines = append(lines[:maxLines], "... (content truncated)")
 	}
@@ -556,6 +1122,70 @@ func loadFilePreview(path string, maxSize int) string {
 	return result
 }
 
+// Encodings recognised by DecodeText
+const (
+	EncodingUTF8    = "UTF-8"
+	EncodingUTF16LE = "UTF-16LE"
+	EncodingUTF16BE = "UTF-16BE"
+)
+
+// DecodeText converts file content to UTF-8. UTF-16 content, marked by a byte
+// order mark or recognisable by its NUL bytes, is transcoded. It returns the
+// detected encoding and false when the content isn't valid text.
+func DecodeText(data []byte) ([]byte, string, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return decodeUTF16(data[2:], false), EncodingUTF16LE, true
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return decodeUTF16(data[2:], true), EncodingUTF16BE, true
+	}
+
+	if bytes.IndexByte(data, 0) >= 0 {
+		// Without a BOM, UTF-16 text is mostly ASCII with every other byte NUL
+		var evenNuls, oddNuls int
+		sample := data
+		if len(sample) > 1024 {
+			sample = sample[:1024]
+		}
+		for i, b := range sample {
+			if b != 0 {
+				continue
+			}
+			if i%2 == 0 {
+				evenNuls++
+			} else {
+				oddNuls++
+			}
+		}
+		pairs := len(sample) / 2
+		switch {
+		case pairs > 0 && oddNuls > pairs*3/4 && evenNuls == 0:
+			return decodeUTF16(data, false), EncodingUTF16LE, true
+		case pairs > 0 && evenNuls > pairs*3/4 && oddNuls == 0:
+			return decodeUTF16(data, true), EncodingUTF16BE, true
+		}
+		return nil, "", false
+	}
+
+	if !utf8.Valid(data) {
+		return nil, "", false
+	}
+	return data, EncodingUTF8, true
+}
+
+// decodeUTF16 transcodes UTF-16 data to UTF-8
+func decodeUTF16(data []byte, bigEndian bool) []byte {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
 // isTextFile checks if a file is likely a text file based on extension
 func isTextFile(ext string) bool {
 	textExtensions := []string{
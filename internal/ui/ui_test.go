@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		want     string
+		encoding string
+		ok       bool
+	}{
+		{
+			name:     "utf-16 le with bom",
+			data:     []byte{0xff, 0xfe, 'h', 0, 'i', 0, 0xe9, 0, '\n', 0, 0x3d, 0xd8, 0x15, 0xdc},
+			want:     "hié\n🐕",
+			encoding: EncodingUTF16LE,
+			ok:       true,
+		},
+		{
+			name:     "utf-16 be with bom",
+			data:     []byte{0xfe, 0xff, 0, 'h', 0, 'i', 0, 0xe9, 0, '\n', 0xd8, 0x3d, 0xdc, 0x15},
+			want:     "hié\n🐕",
+			encoding: EncodingUTF16BE,
+			ok:       true,
+		},
+		{
+			name:     "utf-16 le without bom",
+			data:     []byte{'a', 0, 'b', 0, 'c', 0, 'd', 0},
+			want:     "abcd",
+			encoding: EncodingUTF16LE,
+			ok:       true,
+		},
+		{
+			name:     "utf-8",
+			data:     []byte("héllo\n"),
+			want:     "héllo\n",
+			encoding: EncodingUTF8,
+			ok:       true,
+		},
+		{
+			name:     "utf-8 with bom",
+			data:     []byte("\xef\xbb\xbfhéllo\n"),
+			want:     "\xef\xbb\xbfhéllo\n",
+			encoding: EncodingUTF8,
+			ok:       true,
+		},
+		{
+			name: "invalid utf-8",
+			data: []byte{'a', 0xff, 0xfe, 'b', 0xc3},
+		},
+		{
+			name: "binary",
+			data: []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, encoding, ok := DecodeText(tt.data)
+			if ok != tt.ok {
+				t.Fatalf("DecodeText(%q) ok = %v, want %v", tt.data, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if !bytes.Equal(got, []byte(tt.want)) || encoding != tt.encoding {
+				t.Errorf("DecodeText(%q) = %q, %q, want %q, %q", tt.data, got, encoding, tt.want, tt.encoding)
+			}
+		})
+	}
+}
+
+func TestItemDelegateRenderCache(t *testing.T) {
+	dir := t.TempDir()
+	item := FileItem{Path: dir + "/a.go", Name: "a.go"}
+	delegate := ItemDelegate{HideFileInfo: true}
+	m := list.New([]list.Item{item}, delegate, 80, 10)
+
+	render := func(item FileItem) string {
+		var sb strings.Builder
+		delegate.Render(&sb, m, 0, item)
+		return sb.String()
+	}
+
+	first := render(item)
+	if again := render(item); again != first {
+		t.Errorf("cached row = %q, want %q", again, first)
+	}
+	item.Selected = true
+	if selected := render(item); selected == first || !strings.Contains(selected, "✅") {
+		t.Errorf("row after selecting = %q, want it rendered afresh with a check mark", selected)
+	}
+	m.SetWidth(40)
+	item.Selected = false
+	if resized := render(item); !strings.Contains(resized, "☐") {
+		t.Errorf("row after resizing = %q, want an empty checkbox", resized)
+	}
+}